@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -14,6 +18,7 @@ import (
 	"github.com/go-chi/cors"
 
 	"github.com/pelageech/matharena/internal/data"
+	"github.com/pelageech/matharena/internal/game/generator"
 	"github.com/pelageech/matharena/internal/handlers"
 	"github.com/pelageech/matharena/internal/pkg/ioutil"
 	"github.com/pelageech/matharena/internal/postgres"
@@ -25,6 +30,9 @@ const (
 	readTimeout     = 5 * time.Second
 	writeTimeout    = 10 * time.Second
 	idleTimeout     = 120 * time.Second
+
+	defaultJanitorInterval  = 30 * time.Second
+	defaultSnapshotInterval = time.Minute
 )
 
 func main() {
@@ -107,13 +115,111 @@ func main() {
 
 	// Set up a datalayer
 	dl := data.New(psqlDB, saltLength, duration, []byte(tokenSignKey))
-	sessionDL := data.NewSessionDataLayer(psqlDB, l)
+
+	// load puzzle templates if they are configured
+	var generators *generator.Catalog
+	if path := os.Getenv("PUZZLE_TEMPLATES"); path != "" {
+		generators, err = loadTemplates(path)
+		if err != nil {
+			l.Fatal("Unable to load puzzle templates", "path", path, "error", err)
+		}
+	}
+
+	var sessionOpts []data.SessionDataLayerOpt
+
+	// get the number of recent expressions which must not repeat within a session from env
+	if v := os.Getenv("NO_REPEAT_WINDOW"); v != "" {
+		window, err := strconv.Atoi(v)
+		if err != nil || window < 0 {
+			l.Fatal("NO_REPEAT_WINDOW must be a non-negative integer", "value", v, "error", err)
+		}
+		sessionOpts = append(sessionOpts, data.WithNoRepeatWindow(window))
+	}
+
+	// get the configuration of the adaptive sessions from env
+	adaptive, err := adaptiveConfig()
+	if err != nil {
+		l.Fatal("Invalid adaptive generator configuration", "error", err)
+	}
+	sessionOpts = append(sessionOpts, data.WithAdaptiveConfig(adaptive))
+
+	// keep the sessions in play in the database if the server runs in several replicas
+	switch store := os.Getenv("SESSION_STORE"); store {
+	case "", "memory":
+	case "postgres":
+		sessionOpts = append(sessionOpts, data.WithSessionStore(func(restore data.RestoreFunc) data.SessionStore {
+			return postgres.NewSessionStore(psqlDB, restore)
+		}))
+	default:
+		l.Fatal("SESSION_STORE must be memory or postgres", "value", store)
+	}
+
+	sessionDL := data.NewSessionDataLayer(psqlDB, generators, l, sessionOpts...)
+
+	// get the interval of finishing expired sessions from env
+	janitorInterval := defaultJanitorInterval
+	if v := os.Getenv("JANITOR_INTERVAL"); v != "" {
+		janitorInterval, err = time.ParseDuration(v)
+		if err != nil || janitorInterval <= 0 {
+			l.Fatal("JANITOR_INTERVAL must be a positive duration, e.g. 30s", "value", v, "error", err)
+		}
+	}
+
+	// finish the sessions abandoned by the players in the background
+	janitorCtx, stopJanitor := context.WithCancel(ctx)
+	janitorDone := make(chan struct{})
+	go func() {
+		defer close(janitorDone)
+		sessionDL.RunJanitor(janitorCtx, janitorInterval)
+	}()
+
+	// restore the sessions saved before the restart and save them periodically if it is configured
+	snapshotPath := os.Getenv("SNAPSHOT_PATH")
+	snapshotCtx, stopSnapshots := context.WithCancel(ctx)
+	snapshotsDone := make(chan struct{})
+	if snapshotPath != "" {
+		policy, err := data.ParseRestorePolicy(os.Getenv("SNAPSHOT_RESTORE_POLICY"))
+		if err != nil {
+			l.Fatal("Invalid SNAPSHOT_RESTORE_POLICY", "error", err)
+		}
+
+		snapshotInterval := defaultSnapshotInterval
+		if v := os.Getenv("SNAPSHOT_INTERVAL"); v != "" {
+			snapshotInterval, err = time.ParseDuration(v)
+			if err != nil || snapshotInterval <= 0 {
+				l.Fatal("SNAPSHOT_INTERVAL must be a positive duration, e.g. 1m", "value", v, "error", err)
+			}
+		}
+
+		n, err := sessionDL.LoadSnapshot(ctx, snapshotPath, policy, time.Now())
+		if err != nil {
+			l.Error("Unable to restore sessions", "path", snapshotPath, "error", err)
+		}
+		l.Infof("Restored %d sessions", n)
+
+		go func() {
+			defer close(snapshotsDone)
+			sessionDL.RunSnapshots(snapshotCtx, snapshotPath, snapshotInterval)
+		}()
+	} else {
+		close(snapshotsDone)
+	}
+
+	// retry storing the results of the sessions which failed to be stored
+	retriesCtx, stopRetries := context.WithCancel(ctx)
+	retriesDone := make(chan struct{})
+	go func() {
+		defer close(retriesDone)
+		sessionDL.RunRetries(retriesCtx)
+	}()
+	leaderboardDL := data.NewLeaderboardDataLayer(psqlDB)
 
 	// Set up error writer
 	ew := ioutil.JSONErrorWriter{Logger: l}
 
 	authHandlers := handlers.NewAuthorization(dl, ew, l)
 	sessionHandlers := handlers.NewGameSessionsHandler(sessionDL, ew, l)
+	leaderboardHandlers := handlers.NewLeaderboardHandler(leaderboardDL, ew, l)
 
 	// Set up routes
 	r.Route("/api", func(r chi.Router) {
@@ -121,11 +227,21 @@ func main() {
 		r.Options("/signup", authHandlers.SignUp)
 		r.Post("/signin", authHandlers.SignIn)
 		r.Get("/user/{id}", authHandlers.GetUserInfo)
+		r.Get("/user/{id}/stats", authHandlers.GetUserStats)
+		r.With(authHandlers.Authenticate).Get("/user/{id}/sessions", sessionHandlers.History)
 		r.Route("/session", func(r chi.Router) {
-			r.Post("/create", sessionHandlers.CreateSession)
-			r.Post("/answer", sessionHandlers.Answer)
-			r.Post("/finish", sessionHandlers.Stop)
+			r.With(authHandlers.AuthenticateWebSocket).Get("/ws", sessionHandlers.Play)
+			r.Group(func(r chi.Router) {
+				r.Use(authHandlers.Authenticate)
+				r.Post("/create", sessionHandlers.CreateSession)
+				r.Post("/answer", sessionHandlers.Answer)
+				r.Post("/finish", sessionHandlers.Stop)
+				r.Get("/{id}", sessionHandlers.Session)
+				r.Get("/{id}/answers", sessionHandlers.Answers)
+			})
+			r.With(authHandlers.AuthenticateEvents).Get("/{id}/events", sessionHandlers.Events)
 		})
+		r.With(authHandlers.Authenticate).Get("/leaderboard", leaderboardHandlers.Leaderboard)
 	})
 
 	// create a new server
@@ -138,16 +254,45 @@ func main() {
 		IdleTimeout:  idleTimeout,  // max time for connections using TCP Keep-Alive
 	}
 
+	// end the streams of the sessions on shutdown, the server does not wait for them otherwise
+	s.RegisterOnShutdown(sessionHandlers.Shutdown)
+
 	// start the server
 	go func() {
 		l.Info("Starting server", "port", bindAddress)
 
-		l.Fatal("Error form server", "error", s.ListenAndServe())
+		// ErrServerClosed is returned on shutdown, the sessions are finished in main then
+		if err := s.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			l.Fatal("Error form server", "error", err)
+		}
 	}()
 
-	// trap interrupt and gracefully shutdown the server
+	// expose metrics, e.g. of retries of storing the session results, on a separate
+	// listener if it is configured, so that they are not public with the API
+	var metrics *http.Server
+	if addr := os.Getenv("METRICS_ADDRESS"); addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		metrics = &http.Server{
+			Addr:         addr,
+			Handler:      mux,
+			ErrorLog:     l.StandardLog(),
+			ReadTimeout:  readTimeout,
+			WriteTimeout: writeTimeout,
+			IdleTimeout:  idleTimeout,
+		}
+
+		go func() {
+			l.Info("Starting metrics server", "address", addr)
+			if err := metrics.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				l.Fatal("Error form metrics server", "error", err)
+			}
+		}()
+	}
+
+	// trap interrupt and termination (sent by Docker) and gracefully shutdown the server
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	// Block until a signal is received.
 	sig := <-c
@@ -160,6 +305,84 @@ func main() {
 
 	err = s.Shutdown(cancelCtx)
 	if err != nil {
-		l.Fatal("Error shutting down server", "error", err)
+		l.Error("Error shutting down server", "error", err)
+	}
+	if metrics != nil {
+		if err := metrics.Shutdown(cancelCtx); err != nil {
+			l.Error("Error shutting down metrics server", "error", err)
+		}
+	}
+
+	stopJanitor()
+	<-janitorDone
+
+	stopSnapshots()
+	<-snapshotsDone
+
+	if snapshotPath != "" {
+		// save the sessions in play to continue them after the restart
+		if err := sessionDL.Suspend(cancelCtx, snapshotPath, time.Now()); err != nil {
+			l.Error("Unable to save sessions, they are finished", "path", snapshotPath, "error", err)
+		}
+	} else {
+		// finish the sessions in play, the results which fail to be stored are drained below
+		n := sessionDL.Shutdown(cancelCtx, time.Now())
+		l.Infof("Finished %d active sessions", n)
+	}
+
+	stopRetries()
+	<-retriesDone
+	if err := sessionDL.DrainRetries(cancelCtx); err != nil {
+		l.Error("Unable to store the results of finished sessions", "error", err)
+	}
+}
+
+// loadTemplates reads puzzle templates from a JSON file and compiles them.
+func loadTemplates(path string) (*generator.Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	templates, err := generator.LoadTemplates(f)
+	if err != nil {
+		return nil, err
+	}
+	return generator.NewCatalog(templates)
+}
+
+// adaptiveConfig returns generator.DefaultAdaptiveConfig with the settings
+// overridden by the ADAPTIVE_* env vars.
+func adaptiveConfig() (generator.AdaptiveConfig, error) {
+	cfg := generator.DefaultAdaptiveConfig
+	if v := os.Getenv("ADAPTIVE_TARGET_SUCCESS_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("ADAPTIVE_TARGET_SUCCESS_RATE: %w", err)
+		}
+		cfg.TargetSuccessRate = rate
+	}
+	if v := os.Getenv("ADAPTIVE_TOLERANCE"); v != "" {
+		tolerance, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("ADAPTIVE_TOLERANCE: %w", err)
+		}
+		cfg.Tolerance = tolerance
+	}
+	if v := os.Getenv("ADAPTIVE_WINDOW"); v != "" {
+		window, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("ADAPTIVE_WINDOW: %w", err)
+		}
+		cfg.Window = window
+	}
+	if v := os.Getenv("ADAPTIVE_MAX_LATENCY"); v != "" {
+		latency, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("ADAPTIVE_MAX_LATENCY: %w", err)
+		}
+		cfg.MaxLatency = latency
 	}
+	return cfg, cfg.Validate()
 }
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table game_sessions add column if not exists seed bigint not null default 0;
+alter table game_sessions add column if not exists difficulty varchar(16) not null default '';
+
+comment on column game_sessions.seed
+    is 'Seed of the expression generator, uint64 stored as a signed bigint';
+
+comment on column game_sessions.difficulty
+    is 'Difficulty the session generator started with, e.g. easy';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table game_sessions drop column if exists difficulty;
+alter table game_sessions drop column if exists seed;
+
+-- +goose StatementEnd
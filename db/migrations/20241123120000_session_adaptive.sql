@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table game_sessions add column if not exists adaptive bool not null default false;
+
+comment on column game_sessions.adaptive
+    is 'Whether the difficulty of the generator follows the player performance';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table game_sessions drop column if exists adaptive;
+
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table game_sessions add column if not exists family varchar(64) not null default '';
+
+comment on column game_sessions.family
+    is 'Name of the puzzle family of the generator, empty for the built-in difficulties';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table game_sessions drop column if exists family;
+
+-- +goose StatementEnd
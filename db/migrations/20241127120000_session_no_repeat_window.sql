@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table game_sessions add column if not exists no_repeat_window int not null default 0;
+
+comment on column game_sessions.no_repeat_window
+    is 'Number of recent expressions and answers which must not repeat in the session';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table game_sessions drop column if exists no_repeat_window;
+
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table game_sessions add column if not exists finish_reason varchar(16);
+
+comment on column game_sessions.finish_reason
+    is 'One of stop, timeout, server_shutdown; null while the session is active';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table game_sessions drop column if exists finish_reason;
+
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists session_answers
+(
+    id bigserial primary key,
+    session_id bigint not null,
+    expression jsonb not null,
+    correct_answer bigint not null,
+    answer bigint not null,
+    is_correct bool not null,
+    latency_ms bigint not null,
+    time_left_ms bigint not null,
+    answer_time timestamp not null
+);
+
+comment on column session_answers.expression
+    is 'Expression as the tagged JSON tree of math.AST';
+
+comment on column session_answers.time_left_ms
+    is 'Time left in the session after the answer';
+
+alter table session_answers drop constraint if exists fk_session_of_answer;
+alter table session_answers add constraint fk_session_of_answer foreign key (session_id) references game_sessions(id);
+
+create index if not exists session_answers_session_id on session_answers(session_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists session_answers;
+
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create index if not exists game_sessions_leaderboard
+    on game_sessions(end_time, difficulty, player_id, points)
+    where is_finished;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop index if exists game_sessions_leaderboard;
+
+-- +goose StatementEnd
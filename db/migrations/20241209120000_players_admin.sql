@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table players add column if not exists is_admin bool not null default false;
+
+create index if not exists game_sessions_history
+    on game_sessions(player_id, start_time, id)
+    where is_finished;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop index if exists game_sessions_history;
+
+alter table players drop column if exists is_admin;
+
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists active_sessions
+(
+    id         bigint primary key references game_sessions (id),
+    state      jsonb     not null,
+    version    bigint    not null,
+    expires_at timestamp not null
+);
+
+create index if not exists active_sessions_expires_at on active_sessions (expires_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists active_sessions;
+
+-- +goose StatementEnd
@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -23,6 +24,7 @@ type UserCredentials interface {
 	InsertUser(ctx context.Context, username, hashedPassword, email string) (int, error)
 	GetUserInfo(ctx context.Context, userId int) (username, email string, err error)
 	GetUserID(ctx context.Context, username string) (int64, error)
+	GetUserStats(ctx context.Context, userId int) (models.UserStats, error)
 }
 
 // Datalayer is a struct that helps us to interact with the data.
@@ -54,6 +56,21 @@ func (d *Datalayer) GetUserById(ctx context.Context, id int) (models.UserInfo, e
 	}, nil
 }
 
+// GetUserStats returns the statistics of the user games.
+func (d *Datalayer) GetUserStats(ctx context.Context, id int) (models.UserStats, error) {
+	// Make sure the user exists, the statistics of an unknown user are just empty
+	if _, _, err := d.db.GetUserInfo(ctx, id); err != nil {
+		return models.UserStats{}, fmt.Errorf("unable to get user info in GetUserStats: %w", err)
+	}
+
+	stats, err := d.db.GetUserStats(ctx, id)
+	if err != nil {
+		return models.UserStats{}, fmt.Errorf("unable to get user stats in GetUserStats: %w", err)
+	}
+
+	return stats, nil
+}
+
 // New returns a new Datalayer struct.
 func New(db UserCredentials, saltLength int, tokenExpirationTime time.Duration, signKey []byte) *Datalayer {
 	return &Datalayer{
@@ -112,6 +129,33 @@ func (d *Datalayer) generateToken(username string, userID int64) (string, error)
 	return signedToken, nil
 }
 
+// VerifyToken is a function to check an authorization token issued by SignInUser.
+// The token may be prefixed with "Bearer ". It returns the ID of the token owner.
+func (d *Datalayer) VerifyToken(token string) (int, error) {
+	token = strings.TrimPrefix(token, "Bearer ")
+
+	// Only the algorithm we sign tokens with is accepted
+	parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return d.signKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse token in VerifyToken: %w: %w", models.ErrUnauthorized, err)
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, fmt.Errorf("unexpected claims in VerifyToken: %w", models.ErrUnauthorized)
+	}
+
+	// Numbers are decoded from JSON as float64
+	userID, ok := claims["user_id"].(float64)
+	if !ok || userID < 1 {
+		return 0, fmt.Errorf("invalid user_id claim in VerifyToken: %w", models.ErrUnauthorized)
+	}
+
+	return int(userID), nil
+}
+
 // CreateUser is a function to create a new user.
 func (d *Datalayer) CreateUser(ctx context.Context, user models.User) error {
 	// Check if user with this email already exists
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/pelageech/matharena/internal/game"
+)
+
+// FinishExpired finishes the active sessions whose time is over at timeNow,
+// e.g. because the player left without answering. It returns the number of
+// finished sessions.
+func (ld *SessionDataLayer) FinishExpired(ctx context.Context, timeNow time.Time) int {
+	expired, err := ld.store.Expired(ctx, timeNow)
+	if err != nil {
+		ld.logger.Errorf("janitor: %v", err)
+	}
+	for _, s := range expired {
+		ld.finish(ctx, s, game.FinishTimeout)
+	}
+	return len(expired)
+}
+
+// RunJanitor finishes expired sessions every interval until ctx is done.
+func (ld *SessionDataLayer) RunJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			if n := ld.FinishExpired(ctx, t); n > 0 {
+				ld.logger.Debugf("janitor: finished %d expired sessions", n)
+			}
+		}
+	}
+}
+
+// Shutdown stops accepting new sessions and finishes the active ones the store
+// loses at timeNow with the server shutdown reason. The sessions whose time is
+// already over are finished by timeout.
+func (ld *SessionDataLayer) Shutdown(ctx context.Context, timeNow time.Time) int {
+	ld.closed.Store(true)
+	sessions, err := ld.store.Close(ctx)
+	if err != nil {
+		ld.logger.Errorf("close session store: %v", err)
+	}
+	for _, s := range sessions {
+		ld.shutdown(ctx, s, timeNow)
+	}
+	return len(sessions)
+}
+
+func (ld *SessionDataLayer) shutdown(ctx context.Context, s *game.Session, timeNow time.Time) {
+	if !s.CheckTime(timeNow) {
+		ld.finish(ctx, s, game.FinishTimeout)
+		return
+	}
+	s.Stop(timeNow)
+	ld.finish(ctx, s, game.FinishShutdown)
+}
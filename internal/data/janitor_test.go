@@ -0,0 +1,101 @@
+package data
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/pelageech/matharena/internal/data/mocks"
+	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/generator"
+)
+
+func TestFinishExpired(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 18, 12, 0, 0, 0, time.UTC)
+
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 1, t0, mock.Anything, stored(generator.Options{})).Return(1, nil).Once()
+	db.EXPECT().CreateSession(mock.Anything, 2, t0, mock.Anything, stored(generator.Options{})).Return(2, nil).Once()
+
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+	expired, err := ld.CreateSession(ctx, time.Minute, 1, generator.Options{}, t0)
+	assert.NoError(t, err)
+	live, err := ld.CreateSession(ctx, 2*time.Minute, 2, generator.Options{}, t0)
+	assert.NoError(t, err)
+
+	db.EXPECT().FinishSession(mock.Anything, expired, game.FinishTimeout).Return(nil).Once()
+	assert.Equal(t, 1, ld.FinishExpired(ctx, t0.Add(90*time.Second)))
+	assert.Equal(t, t0.Add(time.Minute), expired.FinishTime())
+
+	// the expired session is finished once, the live one is left alone
+	assert.Equal(t, 0, ld.FinishExpired(ctx, t0.Add(100*time.Second)))
+	got, err := ld.Session(ctx, live.ID(), 2, t0.Add(100*time.Second))
+	assert.NoError(t, err)
+	assert.Same(t, live, got)
+}
+
+func TestRunJanitor(t *testing.T) {
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 1, mock.Anything, mock.Anything, stored(generator.Options{})).Return(1, nil).Once()
+
+	finished := make(chan struct{})
+	db.EXPECT().FinishSession(mock.Anything, mock.Anything, game.FinishTimeout).
+		Run(func(context.Context, *game.Session, game.FinishReason) { close(finished) }).
+		Return(nil).Once()
+
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+	_, err := ld.CreateSession(context.Background(), time.Millisecond, 1, generator.Options{}, time.Now())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ld.RunJanitor(ctx, time.Millisecond)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expired session is not finished")
+	}
+	cancel()
+	<-done
+}
+
+func TestCreateSessionOnShutdown(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 18, 12, 0, 0, 0, time.UTC)
+
+	t.Run("closed store", func(t *testing.T) {
+		db := mocks.NewGameSessionsDB(t)
+		db.EXPECT().CreateSession(mock.Anything, 1, t0, mock.Anything, stored(generator.Options{})).Return(1, nil).Once()
+		db.EXPECT().FinishSession(mock.Anything, mock.Anything, game.FinishShutdown).Return(nil).Once()
+
+		// the store is closed after the data layer has checked it
+		store := NewMemoryStore()
+		_, _ = store.Close(ctx)
+		ld := NewSessionDataLayer(db, nil, log.New(io.Discard), WithSessionStore(func(RestoreFunc) SessionStore {
+			return store
+		}))
+
+		_, err := ld.CreateSession(ctx, time.Minute, 1, generator.Options{}, t0)
+		assert.ErrorIs(t, err, game.ErrPoolClosed)
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		// no session is stored after shutdown
+		db := mocks.NewGameSessionsDB(t)
+		ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+		assert.Equal(t, 0, ld.Shutdown(ctx, t0))
+
+		_, err := ld.CreateSession(ctx, time.Minute, 1, generator.Options{}, t0)
+		assert.ErrorIs(t, err, game.ErrPoolClosed)
+	})
+}
@@ -0,0 +1,54 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pelageech/matharena/internal/models"
+)
+
+// LeaderboardDB is an interface that represents the storage of the finished sessions.
+//
+//go:generate mockery --name LeaderboardDB --output=./ --filename=mocks/leaderboardDB.go --with-expecter
+type LeaderboardDB interface {
+	GetLeaderboard(ctx context.Context, q models.LeaderboardQuery) ([]models.LeaderboardEntry, error)
+	GetLeaderboardEntry(ctx context.Context, q models.LeaderboardQuery, userID int) (*models.LeaderboardEntry, error)
+}
+
+// LeaderboardDataLayer ranks players by their best scores.
+type LeaderboardDataLayer struct {
+	db LeaderboardDB
+}
+
+// NewLeaderboardDataLayer returns a new LeaderboardDataLayer.
+func NewLeaderboardDataLayer(db LeaderboardDB) *LeaderboardDataLayer {
+	return &LeaderboardDataLayer{db: db}
+}
+
+// Leaderboard returns a page of the leaderboard over the window and the entry of the user.
+func (d *LeaderboardDataLayer) Leaderboard(ctx context.Context, window models.LeaderboardWindow, difficulty string, limit, offset, userID int, timeNow time.Time) (models.LeaderboardResponse, error) {
+	q := models.LeaderboardQuery{
+		Since:      window.Since(timeNow),
+		Difficulty: difficulty,
+		Limit:      limit,
+		Offset:     offset,
+	}
+
+	entries, err := d.db.GetLeaderboard(ctx, q)
+	if err != nil {
+		return models.LeaderboardResponse{}, fmt.Errorf("unable to get leaderboard in Leaderboard: %w", err)
+	}
+
+	me, err := d.db.GetLeaderboardEntry(ctx, q, userID)
+	if err != nil {
+		return models.LeaderboardResponse{}, fmt.Errorf("unable to get entry of user %d in Leaderboard: %w", userID, err)
+	}
+
+	return models.LeaderboardResponse{
+		Window:     window,
+		Difficulty: difficulty,
+		Entries:    entries,
+		Me:         me,
+	}, nil
+}
@@ -0,0 +1,206 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pelageech/matharena/internal/game"
+)
+
+const (
+	_defaultRetryBackoff    = time.Second
+	_defaultRetryMaxBackoff = time.Minute
+	_defaultRetryAttempts   = 10
+)
+
+// Metrics of FinishQueue published by expvar.
+var (
+	_finishPending  = expvar.NewInt("finish_retry_pending")
+	_finishRetried  = expvar.NewInt("finish_retry_succeeded")
+	_finishFailures = expvar.NewInt("finish_retry_failures")
+	_finishDropped  = expvar.NewInt("finish_retry_dropped")
+)
+
+type sessionFinisher interface {
+	FinishSession(ctx context.Context, s *game.Session, reason game.FinishReason) error
+}
+
+type finishJob struct {
+	session  *game.Session
+	reason   game.FinishReason
+	attempts int
+	next     time.Time
+}
+
+// FinishQueue retries storing the results of the sessions that could not be
+// stored at once because of a transient error. The delay between attempts doubles
+// from the backoff up to the max backoff. A session is dropped after the given
+// number of failed attempts or at once if the error is not transient.
+type FinishQueue struct {
+	db     sessionFinisher
+	logger *log.Logger
+
+	backoff    time.Duration
+	maxBackoff time.Duration
+	attempts   int
+
+	mu   sync.Mutex
+	jobs []finishJob
+	wake chan struct{}
+}
+
+func NewFinishQueue(db sessionFinisher, logger *log.Logger, backoff, maxBackoff time.Duration, attempts int) *FinishQueue {
+	return &FinishQueue{
+		db:         db,
+		logger:     logger,
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+		attempts:   attempts,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Add schedules the result of the session to be stored after the backoff.
+func (q *FinishQueue) Add(s *game.Session, reason game.FinishReason, timeNow time.Time) {
+	q.mu.Lock()
+	q.jobs = append(q.jobs, finishJob{session: s, reason: reason, attempts: 1, next: timeNow.Add(q.backoff)})
+	q.mu.Unlock()
+	_finishPending.Add(1)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Len returns the number of the sessions waiting for a retry.
+func (q *FinishQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs)
+}
+
+// Run retries the due sessions until ctx is done.
+func (q *FinishQueue) Run(ctx context.Context) {
+	timer := time.NewTimer(q.backoff)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+
+		next := q.retry(ctx, time.Now(), false)
+		timer.Reset(next)
+	}
+}
+
+// Drain retries all the sessions ignoring the backoff until they are stored
+// or ctx is done. It must not be called concurrently with Run.
+func (q *FinishQueue) Drain(ctx context.Context) error {
+	for {
+		q.retry(ctx, time.Now(), true)
+		n := q.Len()
+		if n == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			q.mu.Lock()
+			for _, j := range q.jobs {
+				q.logger.Errorf("finish session %v lost: user %v, points %v, end %v, reason %v",
+					j.session.ID(), j.session.UserID(), j.session.Score(), j.session.FinishTime(), j.reason)
+			}
+			q.mu.Unlock()
+			return fmt.Errorf("drain finish queue: %d sessions left: %w", n, ctx.Err())
+		case <-time.After(q.backoff):
+		}
+	}
+}
+
+// retry tries to store the due sessions, or all of them if all is set.
+// It returns the time until the next session is due.
+func (q *FinishQueue) retry(ctx context.Context, timeNow time.Time, all bool) time.Duration {
+	q.mu.Lock()
+	var due []finishJob
+	pending := q.jobs[:0]
+	for _, j := range q.jobs {
+		if all || !j.next.After(timeNow) {
+			due = append(due, j)
+		} else {
+			pending = append(pending, j)
+		}
+	}
+	q.jobs = pending
+	q.mu.Unlock()
+
+	var failed []finishJob
+	for _, j := range due {
+		err := q.db.FinishSession(ctx, j.session, j.reason)
+		if err == nil {
+			q.logger.Infof("finish session %v: stored after %d attempts", j.session.ID(), j.attempts+1)
+			_finishPending.Add(-1)
+			_finishRetried.Add(1)
+			continue
+		}
+
+		_finishFailures.Add(1)
+		j.attempts++
+		if !isTransient(err) || j.attempts >= q.attempts {
+			q.logger.Errorf("finish session %v dropped after %d attempts: user %v, points %v, end %v, reason %v: %v",
+				j.session.ID(), j.attempts, j.session.UserID(), j.session.Score(), j.session.FinishTime(), j.reason, err)
+			_finishPending.Add(-1)
+			_finishDropped.Add(1)
+			continue
+		}
+
+		q.logger.Warnf("finish session %v: attempt %d: %v", j.session.ID(), j.attempts, err)
+		j.next = timeNow.Add(q.delay(j.attempts))
+		failed = append(failed, j)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs = append(q.jobs, failed...)
+
+	next := q.maxBackoff
+	for _, j := range q.jobs {
+		next = min(next, j.next.Sub(timeNow))
+	}
+	return max(next, 0)
+}
+
+// isTransient reports whether the error of storing a result may go away
+// if it is retried, e.g. a broken connection or a timeout. Errors like
+// a missing session are permanent.
+func isTransient(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &netErr)
+}
+
+// delay returns the backoff after the given number of failed attempts.
+func (q *FinishQueue) delay(attempts int) time.Duration {
+	d := q.backoff
+	for range attempts - 1 {
+		if d >= q.maxBackoff/2 {
+			return q.maxBackoff
+		}
+		d *= 2
+	}
+	return d
+}
@@ -0,0 +1,161 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/pelageech/matharena/internal/data/mocks"
+	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/models"
+)
+
+// flakyFinisher fails the first fails calls of FinishSession with err,
+// a refused connection if it is nil.
+type flakyFinisher struct {
+	mu       sync.Mutex
+	fails    int
+	err      error
+	calls    int
+	finished []game.SessionID
+}
+
+func (f *flakyFinisher) FinishSession(_ context.Context, s *game.Session, _ game.FinishReason) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.fails {
+		if f.err != nil {
+			return f.err
+		}
+		return &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+	f.finished = append(f.finished, s.ID())
+	return nil
+}
+
+func (f *flakyFinisher) result() (int, []game.SessionID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls, f.finished
+}
+
+func newTestSession(t *testing.T, id game.SessionID) *game.Session {
+	s, err := game.NewSession(1, time.Minute, generator.NewEasyGenerator(1), time.Now(), game.WithCustomID(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestFinishQueueRun(t *testing.T) {
+	db := &flakyFinisher{fails: 2}
+	q := NewFinishQueue(db, log.New(io.Discard), time.Millisecond, 4*time.Millisecond, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx)
+	}()
+
+	q.Add(newTestSession(t, 1), game.FinishStopped, time.Now())
+
+	deadline := time.Now().Add(time.Second)
+	for q.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	calls, finished := db.result()
+	if calls != 3 || len(finished) != 1 || finished[0] != 1 {
+		t.Fatalf("got %d calls, finished %v, want 3 calls, finished [1]", calls, finished)
+	}
+}
+
+func TestFinishQueueDrop(t *testing.T) {
+	db := &flakyFinisher{fails: 100}
+	q := NewFinishQueue(db, log.New(io.Discard), time.Millisecond, time.Millisecond, 3)
+
+	q.Add(newTestSession(t, 1), game.FinishStopped, time.Now())
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first attempt was made before Add
+	if calls, _ := db.result(); calls != 2 {
+		t.Fatalf("got %d calls, want 2", calls)
+	}
+}
+
+func TestFinishQueueDropPermanent(t *testing.T) {
+	db := &flakyFinisher{fails: 100, err: fmt.Errorf("finish: %w", game.ErrSessionNotFound)}
+	q := NewFinishQueue(db, log.New(io.Discard), time.Millisecond, time.Millisecond, 10)
+
+	q.Add(newTestSession(t, 1), game.FinishStopped, time.Now())
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a permanent error is not retried
+	if calls, _ := db.result(); calls != 1 {
+		t.Fatalf("got %d calls, want 1", calls)
+	}
+}
+
+func TestFinishQueueDrainTimeout(t *testing.T) {
+	db := &flakyFinisher{fails: 100}
+	q := NewFinishQueue(db, log.New(io.Discard), time.Millisecond, time.Millisecond, 1000)
+
+	q.Add(newTestSession(t, 1), game.FinishShutdown, time.Now())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := q.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if q.Len() != 1 {
+		t.Fatalf("got %d pending, want 1", q.Len())
+	}
+}
+
+func TestFinishQueueDelay(t *testing.T) {
+	q := NewFinishQueue(nil, nil, time.Second, 5*time.Second, 10)
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := q.delay(i + 1); got != w {
+			t.Errorf("delay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestFinishPermanentError(t *testing.T) {
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 1, mock.Anything, mock.Anything, stored(generator.Options{})).Return(1, nil)
+	db.EXPECT().FinishSession(mock.Anything, mock.Anything, game.FinishStopped).Return(models.ErrForbidden).Once()
+
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+	s, err := ld.CreateSession(context.Background(), time.Minute, 1, generator.Options{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ld.Stop(context.Background(), s.ID(), 1, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the result which cannot be stored is not retried
+	if n := ld.retries.Len(); n != 0 {
+		t.Fatalf("got %d pending, want 0", n)
+	}
+}
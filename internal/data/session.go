@@ -7,88 +7,367 @@ import (
 	"github.com/charmbracelet/log"
 	"github.com/pelageech/matharena/internal/game"
 	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/models"
+	"sync/atomic"
 	"time"
 )
 
+// GameSessionsDB is an interface that represents the storage of game sessions.
+//
+//go:generate mockery --name GameSessionsDB --output=./ --filename=mocks/gameSessionsDB.go --with-expecter
 type GameSessionsDB interface {
-	CreateSession(ctx context.Context, userId int, startTime time.Time) (game.SessionID, error)
-	FinishSession(ctx context.Context, userID int, id game.SessionID, finishTime time.Time) error
+	// CreateSession stores a new session with the seed and the options of its generator.
+	CreateSession(ctx context.Context, userId int, startTime time.Time, seed uint64, opts generator.Options) (game.SessionID, error)
+	// FinishSession marks the session finished and stores its finish time, score and finish reason.
+	FinishSession(ctx context.Context, s *game.Session, reason game.FinishReason) error
+	GetUserIDBySession(ctx context.Context, id game.SessionID) (int, error)
+	InsertAnswer(ctx context.Context, id game.SessionID, a game.AnswerRecord) error
+	GetAnswers(ctx context.Context, id game.SessionID) ([]game.AnswerRecord, error)
+	GetSessionHistory(ctx context.Context, q models.SessionHistoryQuery) ([]models.SessionHistoryItem, error)
+	IsAdmin(ctx context.Context, userID int) (bool, error)
+	IsSessionFinished(ctx context.Context, id game.SessionID) (bool, error)
+	GetSessionResult(ctx context.Context, id game.SessionID) (models.SessionResult, error)
 }
 
+// _defaultNoRepeatWindow is the number of recent expressions and answers
+// which must not repeat within a session.
+const _defaultNoRepeatWindow = 10
+
 type SessionDataLayer struct {
 	logger         *log.Logger
 	db             GameSessionsDB
-	activeSessions *game.ActiveSessionsPool
+	store          SessionStore
+	newStore       func(RestoreFunc) SessionStore
+	generators     *generator.Catalog
+	noRepeatWindow int
+	adaptive       generator.AdaptiveConfig
+	retries        *FinishQueue
+	closed         atomic.Bool
+
+	retryBackoff    time.Duration
+	retryMaxBackoff time.Duration
+	retryAttempts   int
+}
+
+type SessionDataLayerOpt func(*SessionDataLayer)
+
+// WithNoRepeatWindow sets the number of recent expressions and answers
+// which must not repeat within a session. Zero allows repeats.
+func WithNoRepeatWindow(window int) SessionDataLayerOpt {
+	return func(ld *SessionDataLayer) {
+		ld.noRepeatWindow = window
+	}
+}
+
+// WithAdaptiveConfig configures the generators of the adaptive sessions.
+func WithAdaptiveConfig(cfg generator.AdaptiveConfig) SessionDataLayerOpt {
+	return func(ld *SessionDataLayer) {
+		ld.adaptive = cfg
+	}
+}
+
+// WithFinishRetry configures retries of storing the results of the finished sessions.
+// The delay between attempts doubles from backoff up to maxBackoff, a result
+// is dropped after the given number of attempts.
+func WithFinishRetry(backoff, maxBackoff time.Duration, attempts int) SessionDataLayerOpt {
+	return func(ld *SessionDataLayer) {
+		ld.retryBackoff = backoff
+		ld.retryMaxBackoff = maxBackoff
+		ld.retryAttempts = attempts
+	}
+}
+
+// WithSessionStore sets the store of the active sessions. The store is created
+// with the function restoring sessions from their states. The sessions are kept
+// in the memory of the server by default.
+func WithSessionStore(newStore func(restore RestoreFunc) SessionStore) SessionDataLayerOpt {
+	return func(ld *SessionDataLayer) {
+		ld.newStore = newStore
+	}
 }
 
-func NewSessionDataLayer(db GameSessionsDB, logger *log.Logger) *SessionDataLayer {
-	return &SessionDataLayer{
+// NewSessionDataLayer creates a data layer for game sessions. The generators of
+// the sessions are created by the catalog, a nil catalog provides only
+// the built-in difficulties.
+func NewSessionDataLayer(db GameSessionsDB, generators *generator.Catalog, logger *log.Logger, opts ...SessionDataLayerOpt) *SessionDataLayer {
+	ld := &SessionDataLayer{
 		db:             db,
-		activeSessions: game.NewActiveSessionsPool(),
+		generators:     generators,
+		noRepeatWindow: _defaultNoRepeatWindow,
+		adaptive:       generator.DefaultAdaptiveConfig,
 		logger:         logger,
+
+		retryBackoff:    _defaultRetryBackoff,
+		retryMaxBackoff: _defaultRetryMaxBackoff,
+		retryAttempts:   _defaultRetryAttempts,
+	}
+
+	for _, opt := range opts {
+		opt(ld)
 	}
+	ld.retries = NewFinishQueue(db, logger, ld.retryBackoff, ld.retryMaxBackoff, ld.retryAttempts)
+	ld.store = NewMemoryStore()
+	if ld.newStore != nil {
+		ld.store = ld.newStore(ld.Restore)
+	}
+
+	return ld
 }
 
-func (ld *SessionDataLayer) CreateSession(ctx context.Context, timeStart time.Duration, userID int, _ generator.Difficulty, timeNow time.Time) (*game.Session, error) {
-	id, err := ld.db.CreateSession(ctx, userID, timeNow)
+func (ld *SessionDataLayer) CreateSession(ctx context.Context, timeStart time.Duration, userID int, opts generator.Options, timeNow time.Time) (*game.Session, error) {
+	seed := generator.NewSeed()
+	opts.NoRepeatWindow = ld.noRepeatWindow
+	gen, err := ld.generators.New(opts, seed, ld.adaptive)
+	if err != nil {
+		return nil, fmt.Errorf("new generator: %w", err)
+	}
+
+	// a session created on shutdown would be left unfinished
+	if ld.closed.Load() {
+		return nil, fmt.Errorf("create session: %w", game.ErrPoolClosed)
+	}
+
+	id, err := ld.db.CreateSession(ctx, userID, timeNow, seed, opts)
 	if err != nil {
 		return nil, fmt.Errorf("db create session: %w", err)
 	}
 
-	s := game.NewSession(userID, timeStart, &generator.EasyGenerator{}, timeNow, game.WithCustomID(id))
-	if err := ld.activeSessions.Put(s); err != nil {
+	s, err := game.NewSession(userID, timeStart, gen, timeNow, game.WithCustomID(id), game.WithSeed(seed), game.WithGeneratorOptions(opts))
+	if err != nil {
+		return nil, fmt.Errorf("new session: %w", err)
+	}
+	if err := ld.store.Put(ctx, s); err != nil {
+		// the stored session is finished, e.g. if the server started shutting down after the check
+		reason := game.FinishStopped
+		if errors.Is(err, game.ErrPoolClosed) {
+			reason = game.FinishShutdown
+		}
+		s.Stop(timeNow)
+		ld.finish(ctx, s, reason)
 		return nil, fmt.Errorf("set session active: %w", err)
 	}
 	return s, nil
 }
 
 func (ld *SessionDataLayer) Answer(ctx context.Context, sessionID game.SessionID, answer, userID int, timeNow time.Time) (*game.Session, error) {
-	s, err := ld.activeSessions.Get(sessionID, timeNow)
-	if errors.Is(err, game.ErrTimeIsLeft) {
+	var a *game.AnswerRecord
+	s, err := ld.play(ctx, sessionID, userID, timeNow, func(s *game.Session) error {
+		var err error
+		a, err = s.Submit(answer, timeNow)
+		return err
+	})
+	if a != nil {
+		if err := ld.db.InsertAnswer(ctx, sessionID, *a); err != nil {
+			ld.logger.Errorf("sid %v: insert answer: %v", sessionID, err)
+		}
+	}
+	if errors.Is(err, game.ErrAnswerIsIncorrect) {
+		ld.logger.Debugf("sid: %v, ans: %v: answer is incorrect", sessionID, answer)
+	} else if err != nil {
+		return nil, fmt.Errorf("answer: %w", err)
+	}
+
+	return s, nil
+}
+
+// Skip replaces the current expression of the session with a new one, see game.Session.Skip.
+func (ld *SessionDataLayer) Skip(ctx context.Context, sessionID game.SessionID, userID int, timeNow time.Time) (*game.Session, error) {
+	s, err := ld.play(ctx, sessionID, userID, timeNow, func(s *game.Session) error {
+		return s.Skip(timeNow)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("skip: %w", err)
+	}
+	return s, nil
+}
+
+// play makes the move in the session of the user and finishes the session
+// if the move fails, except for an incorrect answer.
+func (ld *SessionDataLayer) play(ctx context.Context, sessionID game.SessionID, userID int, timeNow time.Time, move func(*game.Session) error) (*game.Session, error) {
+	moved := false
+	s, err := ld.store.Update(ctx, sessionID, timeNow, func(s *game.Session) error {
+		// the session of another user is not changed
 		if s.UserID() != userID {
-			ld.logger.Infof("%v %v", s.UserID(), userID)
-			return nil, errors.New("invalid session")
+			return models.ErrForbidden
 		}
 
-		_ = ld.Stop(ctx, sessionID, userID, s.FinishTime())
-		return nil, fmt.Errorf("sid %v: already stopped", sessionID)
+		moved = true
+		return move(s)
+	})
+	if s == nil {
+		return nil, fmt.Errorf("get session %v: %w", sessionID, err)
 	}
-	if err != nil {
+	if !moved && errors.Is(err, game.ErrTimeIsLeft) {
+		// the store has already removed the expired session, it is finished
+		// whoever asked for it
+		ld.finish(ctx, s, game.FinishTimeout)
+	}
+	if s.UserID() != userID {
+		return nil, fmt.Errorf("sid %v: %w", sessionID, models.ErrForbidden)
+	}
+	if !moved {
+		if errors.Is(err, game.ErrTimeIsLeft) {
+			return nil, fmt.Errorf("sid %v: already stopped: %w", sessionID, game.ErrTimeIsLeft)
+		}
 		return nil, fmt.Errorf("get session %v: %w", sessionID, err)
 	}
 
+	switch {
+	case err == nil, errors.Is(err, game.ErrAnswerIsIncorrect):
+		return s, err
+	case errors.Is(err, game.ErrTimeIsLeft):
+		ld.remove(ctx, s, game.FinishTimeout)
+	default:
+		s.Stop(timeNow)
+		ld.remove(ctx, s, game.FinishStopped)
+	}
+	return nil, err
+}
+
+func (ld *SessionDataLayer) Stop(ctx context.Context, sessionID game.SessionID, userID int, timeNow time.Time) error {
+	s, err := ld.store.Get(ctx, sessionID, timeNow)
+	if errors.Is(err, game.ErrTimeIsLeft) {
+		// the store has already removed the expired session
+		ld.finish(ctx, s, game.FinishTimeout)
+	} else if err != nil {
+		return fmt.Errorf("get session %v: %w", sessionID, err)
+	}
+
 	if s.UserID() != userID {
-		ld.logger.Infof("%v %v", s.UserID(), userID)
-		return nil, errors.New("invalid session")
+		return fmt.Errorf("sid %v: %w", sessionID, models.ErrForbidden)
+	}
+	if err != nil {
+		return fmt.Errorf("sid %v: already stopped: %w", sessionID, err)
 	}
 
-	err = s.Answer(answer, timeNow)
-	if errors.Is(err, game.ErrAnswerIsIncorrect) {
-		ld.logger.Debugf("sid: %v, ans: %v: answer is incorrect", sessionID, answer)
+	s.Stop(timeNow)
+	ld.remove(ctx, s, game.FinishStopped)
+	return nil
+}
+
+// Session returns the active session of the user without changing it. A session
+// whose time is over is finished by timeout and returned with game.ErrTimeIsLeft.
+func (ld *SessionDataLayer) Session(ctx context.Context, sessionID game.SessionID, userID int, timeNow time.Time) (*game.Session, error) {
+	s, err := ld.store.Get(ctx, sessionID, timeNow)
+	if errors.Is(err, game.ErrTimeIsLeft) {
+		// the store has already removed the expired session
+		ld.finish(ctx, s, game.FinishTimeout)
 	} else if err != nil {
-		_ = ld.Stop(ctx, sessionID, userID, s.FinishTime())
-		return nil, fmt.Errorf("answer: %w", err)
+		return nil, fmt.Errorf("get session %v: %w", sessionID, err)
 	}
 
-	return s, nil
+	if s.UserID() != userID {
+		return nil, fmt.Errorf("sid %v: %w", sessionID, models.ErrForbidden)
+	}
+	return s, err
 }
 
-func (ld *SessionDataLayer) Stop(ctx context.Context, sessionID game.SessionID, userID int, timeNow time.Time) error {
-	s, err := ld.activeSessions.Get(sessionID, timeNow)
+// Result returns the stored result of the finished session of the user.
+// A session which is not finished yet is not found.
+func (ld *SessionDataLayer) Result(ctx context.Context, sessionID game.SessionID, userID int) (models.SessionResult, error) {
+	res, err := ld.db.GetSessionResult(ctx, sessionID)
 	if err != nil {
-		return fmt.Errorf("get session %v: %w", sessionID, err)
+		return models.SessionResult{}, fmt.Errorf("get session %v: %w", sessionID, err)
 	}
+	if res.UserID != userID {
+		return models.SessionResult{}, fmt.Errorf("sid %v: %w", sessionID, models.ErrForbidden)
+	}
+	if !res.IsFinished {
+		return models.SessionResult{}, fmt.Errorf("sid %v: not finished: %w", sessionID, game.ErrSessionNotFound)
+	}
+	return res, nil
+}
 
-	if s.UserID() != userID {
-		return errors.New("invalid session")
+// Answers returns the answers given in the session of the user.
+func (ld *SessionDataLayer) Answers(ctx context.Context, sessionID game.SessionID, userID int) ([]game.AnswerRecord, error) {
+	ownerID, err := ld.db.GetUserIDBySession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("get session %v: %w", sessionID, err)
+	}
+	if ownerID != userID {
+		return nil, fmt.Errorf("sid %v: %w", sessionID, models.ErrForbidden)
 	}
 
-	s.Stop(timeNow)
-	ld.activeSessions.Delete(sessionID)
-	err = ld.db.FinishSession(ctx, userID, sessionID, timeNow)
-	if err != nil { // todo: create a pool of unfinished session and finish them asynchronously?
-		return fmt.Errorf("finish session %v: %w", sessionID, err)
+	answers, err := ld.db.GetAnswers(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("get answers %v: %w", sessionID, err)
 	}
+	return answers, nil
+}
 
-	return nil
+// History returns a page of the finished sessions of q.UserID. Only the user
+// or an administrator may list them.
+func (ld *SessionDataLayer) History(ctx context.Context, callerID int, q models.SessionHistoryQuery) (models.SessionHistoryResponse, error) {
+	if callerID != q.UserID {
+		isAdmin, err := ld.db.IsAdmin(ctx, callerID)
+		if err != nil {
+			return models.SessionHistoryResponse{}, fmt.Errorf("check admin %v: %w", callerID, err)
+		}
+		if !isAdmin {
+			return models.SessionHistoryResponse{}, fmt.Errorf("history of %v: %w", q.UserID, models.ErrForbidden)
+		}
+	}
+
+	if q.After != nil && q.After.Sort != q.Sort {
+		return models.SessionHistoryResponse{}, fmt.Errorf("cursor of %q sort, not %q: %w", q.After.Sort, q.Sort, models.ErrInvalidCursor)
+	}
+
+	// one more session tells whether there is the next page
+	limit := q.Limit
+	q.Limit++
+	items, err := ld.db.GetSessionHistory(ctx, q)
+	if err != nil {
+		return models.SessionHistoryResponse{}, fmt.Errorf("history of %v: %w", q.UserID, err)
+	}
+
+	resp := models.SessionHistoryResponse{Sessions: items}
+	if len(items) > limit {
+		resp.Sessions = items[:limit]
+		resp.NextCursor = items[limit-1].Cursor(q.Sort).Encode()
+	}
+	return resp, nil
+}
+
+// remove removes the stopped session from the store and finishes it. A session
+// removed concurrently, e.g. by a stop and a late answer, is finished by the remover.
+func (ld *SessionDataLayer) remove(ctx context.Context, s *game.Session, reason game.FinishReason) {
+	removed, err := ld.store.Delete(ctx, s.ID())
+	if err != nil {
+		// the session stays in the store and is finished by the janitor
+		ld.logger.Errorf("remove session %v: %v", s.ID(), err)
+		return
+	}
+	if removed {
+		ld.finish(ctx, s, reason)
+	}
+}
+
+// finish stores the result of the session removed from the store.
+// If the result cannot be stored because of a transient error, it is retried in the background.
+func (ld *SessionDataLayer) finish(ctx context.Context, s *game.Session, reason game.FinishReason) {
+	if !s.MarkFinished() {
+		return
+	}
+	err := ld.db.FinishSession(ctx, s, reason)
+	switch {
+	case err == nil:
+	case isTransient(err):
+		ld.logger.Warnf("finish session %v: %v, will retry", s.ID(), err)
+		ld.retries.Add(s, reason, time.Now())
+	default:
+		ld.logger.Errorf("finish session %v dropped: user %v, points %v, end %v, reason %v: %v",
+			s.ID(), s.UserID(), s.Score(), s.FinishTime(), reason, err)
+		_finishDropped.Add(1)
+	}
+}
+
+// RunRetries retries storing the results of the finished sessions until ctx is done.
+func (ld *SessionDataLayer) RunRetries(ctx context.Context) {
+	ld.retries.Run(ctx)
+}
+
+// DrainRetries stores the results waiting for a retry until ctx is done.
+// It must be called after RunRetries returns.
+func (ld *SessionDataLayer) DrainRetries(ctx context.Context) error {
+	return ld.retries.Drain(ctx)
 }
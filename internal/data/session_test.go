@@ -0,0 +1,295 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/pelageech/matharena/internal/data/mocks"
+	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/models"
+)
+
+// stored returns the options stored with the session created with opts
+// by a data layer with the default settings.
+func stored(opts generator.Options) generator.Options {
+	opts.NoRepeatWindow = _defaultNoRepeatWindow
+	return opts
+}
+
+func TestSessionDataLayerSession(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 16, 12, 0, 0, 0, time.UTC)
+
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 1, t0, mock.Anything, stored(generator.Options{})).Return(5, nil)
+
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+	s, err := ld.CreateSession(ctx, time.Minute, 1, generator.Options{}, t0)
+	assert.NoError(t, err)
+	want := s.State()
+
+	got, err := ld.Session(ctx, s.ID(), 1, t0.Add(10*time.Second))
+	assert.NoError(t, err)
+	assert.Same(t, s, got)
+	assert.Equal(t, want, got.State())
+	assert.Equal(t, 50*time.Second, got.State().Remaining(t0.Add(10*time.Second)))
+
+	_, err = ld.Session(ctx, s.ID(), 2, t0.Add(10*time.Second))
+	assert.ErrorIs(t, err, models.ErrForbidden)
+
+	db.EXPECT().FinishSession(mock.Anything, s, game.FinishTimeout).Return(nil).Once()
+	got, err = ld.Session(ctx, s.ID(), 1, t0.Add(2*time.Minute))
+	assert.ErrorIs(t, err, game.ErrTimeIsLeft)
+	assert.Equal(t, t0.Add(time.Minute), got.FinishTime())
+
+	_, err = ld.Session(ctx, s.ID(), 1, t0.Add(2*time.Minute))
+	assert.ErrorIs(t, err, game.ErrSessionNotFound)
+}
+
+func TestSessionDataLayerFinish(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 16, 12, 0, 0, 0, time.UTC)
+	opts := generator.Options{Difficulty: generator.Medium, Adaptive: true}
+
+	db := mocks.NewGameSessionsDB(t)
+	// the options the session starts with are stored at once, the result at finish
+	db.EXPECT().CreateSession(mock.Anything, 1, t0, mock.Anything, stored(opts)).Return(5, nil).Once()
+	db.EXPECT().InsertAnswer(mock.Anything, game.SessionID(5), mock.Anything).Return(nil).Once()
+	db.EXPECT().FinishSession(mock.Anything, mock.MatchedBy(func(s *game.Session) bool {
+		return s.ID() == 5 && s.UserID() == 1 && s.Score() == 1 && s.FinishTime().Equal(t0.Add(10*time.Second))
+	}), game.FinishStopped).Return(nil).Once()
+
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+	s, err := ld.CreateSession(ctx, time.Minute, 1, opts, t0)
+	assert.NoError(t, err)
+	_, err = ld.Answer(ctx, s.ID(), s.CurrentExpression().Calculate(), 1, t0.Add(time.Second))
+	assert.NoError(t, err)
+	assert.NoError(t, ld.Stop(ctx, s.ID(), 1, t0.Add(10*time.Second)))
+
+	// the session is finished once
+	assert.ErrorIs(t, ld.Stop(ctx, s.ID(), 1, t0.Add(11*time.Second)), game.ErrSessionNotFound)
+}
+
+// pausedStore holds the updates of the sessions after getting them, so that
+// they are applied concurrently with other requests.
+type pausedStore struct {
+	*MemoryStore
+	got, resume chan struct{}
+}
+
+func (p *pausedStore) Update(ctx context.Context, id game.SessionID, timeNow time.Time, fn func(*game.Session) error) (*game.Session, error) {
+	return p.MemoryStore.Update(ctx, id, timeNow, func(s *game.Session) error {
+		p.got <- struct{}{}
+		<-p.resume
+		return fn(s)
+	})
+}
+
+func TestSessionDataLayerStopAnswer(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 16, 12, 0, 0, 0, time.UTC)
+
+	store := &pausedStore{MemoryStore: NewMemoryStore(), got: make(chan struct{}), resume: make(chan struct{})}
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 1, t0, mock.Anything, stored(generator.Options{})).Return(5, nil).Once()
+	db.EXPECT().FinishSession(mock.Anything, mock.MatchedBy(func(s *game.Session) bool {
+		return s.Score() == 0 && s.FinishTime().Equal(t0.Add(time.Second))
+	}), game.FinishStopped).Return(nil).Once()
+
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard), WithSessionStore(func(RestoreFunc) SessionStore {
+		return store
+	}))
+	s, err := ld.CreateSession(ctx, time.Minute, 1, generator.Options{}, t0)
+	assert.NoError(t, err)
+	answer := s.CurrentExpression().Calculate()
+
+	errc := make(chan error, 1)
+	go func() {
+		// an unexpected call of the mock ends the goroutine
+		err := errors.New("answer is not handled")
+		defer func() { errc <- err }()
+		_, err = ld.Answer(ctx, s.ID(), answer, 1, t0.Add(2*time.Second))
+	}()
+	<-store.got
+	assert.NoError(t, ld.Stop(ctx, s.ID(), 1, t0.Add(time.Second)))
+	close(store.resume)
+
+	// the answer to the stopped session is neither counted nor stored
+	assert.ErrorIs(t, <-errc, game.ErrTimeIsLeft)
+	assert.Equal(t, 0, s.Score())
+	assert.Equal(t, t0.Add(time.Second), s.FinishTime())
+}
+
+func TestSessionDataLayerAnswers(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 17, 12, 0, 0, 0, time.UTC)
+
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 1, t0, mock.Anything, stored(generator.Options{})).Return(5, nil)
+
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+	s, err := ld.CreateSession(ctx, time.Minute, 1, generator.Options{}, t0)
+	assert.NoError(t, err)
+
+	// the answer is inserted as it is given
+	expression := s.CurrentExpression()
+	want := game.AnswerRecord{
+		Expression:    expression,
+		CorrectAnswer: expression.Calculate(),
+		Answer:        expression.Calculate() + 1,
+		Latency:       time.Second,
+		TimeLeft:      54 * time.Second,
+		Time:          t0.Add(time.Second),
+	}
+	db.EXPECT().InsertAnswer(mock.Anything, game.SessionID(5), want).Return(nil).Once()
+	_, err = ld.Answer(ctx, s.ID(), want.Answer, 1, want.Time)
+	assert.NoError(t, err)
+
+	db.EXPECT().GetUserIDBySession(mock.Anything, game.SessionID(5)).Return(1, nil)
+	db.EXPECT().GetAnswers(mock.Anything, game.SessionID(5)).Return([]game.AnswerRecord{want}, nil).Once()
+	answers, err := ld.Answers(ctx, 5, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []game.AnswerRecord{want}, answers)
+
+	_, err = ld.Answers(ctx, 5, 2)
+	assert.ErrorIs(t, err, models.ErrForbidden)
+
+	db.EXPECT().GetUserIDBySession(mock.Anything, game.SessionID(6)).Return(0, game.ErrSessionNotFound)
+	_, err = ld.Answers(ctx, 6, 1)
+	assert.ErrorIs(t, err, game.ErrSessionNotFound)
+}
+
+func TestSessionDataLayerHistory(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 9, 12, 0, 0, 0, time.UTC)
+	items := []models.SessionHistoryItem{
+		{ID: 3, StartTime: t0.Add(2 * time.Hour), Points: 5},
+		{ID: 2, StartTime: t0.Add(time.Hour), Points: 7},
+		{ID: 1, StartTime: t0, Points: 6},
+	}
+
+	db := mocks.NewGameSessionsDB(t)
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+
+	t.Run("pages", func(t *testing.T) {
+		q := models.SessionHistoryQuery{UserID: 1, Sort: models.SortByStartTime, Limit: 2}
+
+		// one more session is requested to know whether there is the next page
+		first := q
+		first.Limit = 3
+		db.EXPECT().GetSessionHistory(mock.Anything, first).Return(items, nil).Once()
+		resp, err := ld.History(ctx, 1, q)
+		assert.NoError(t, err)
+		assert.Equal(t, items[:2], resp.Sessions)
+
+		cursor, err := models.DecodeSessionCursor(resp.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, items[1].Cursor(models.SortByStartTime), cursor)
+
+		q.After = &cursor
+		second := q
+		second.Limit = 3
+		db.EXPECT().GetSessionHistory(mock.Anything, second).Return(items[2:], nil).Once()
+		resp, err = ld.History(ctx, 1, q)
+		assert.NoError(t, err)
+		assert.Equal(t, items[2:], resp.Sessions)
+		assert.Empty(t, resp.NextCursor)
+
+		// the cursor does not continue the history sorted another way
+		q.Sort = models.SortByScore
+		_, err = ld.History(ctx, 1, q)
+		assert.ErrorIs(t, err, models.ErrInvalidCursor)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		db.EXPECT().IsAdmin(mock.Anything, 2).Return(false, nil).Once()
+		_, err := ld.History(ctx, 2, models.SessionHistoryQuery{UserID: 1, Limit: 2})
+		assert.ErrorIs(t, err, models.ErrForbidden)
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		q := models.SessionHistoryQuery{UserID: 1, Limit: 5}
+		db.EXPECT().IsAdmin(mock.Anything, 3).Return(true, nil).Once()
+		db.EXPECT().GetSessionHistory(mock.Anything, mock.Anything).Return(items, nil).Once()
+		resp, err := ld.History(ctx, 3, q)
+		assert.NoError(t, err)
+		assert.Equal(t, items, resp.Sessions)
+		assert.Empty(t, resp.NextCursor)
+	})
+}
+
+func TestSessionDataLayerExpired(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 19, 12, 0, 0, 0, time.UTC)
+
+	// the expired session is finished by timeout whoever finds it
+	for name, call := range map[string]func(ld *SessionDataLayer, id game.SessionID) error{
+		"stop": func(ld *SessionDataLayer, id game.SessionID) error {
+			return ld.Stop(ctx, id, 1, t0.Add(2*time.Minute))
+		},
+		"stop of another user": func(ld *SessionDataLayer, id game.SessionID) error {
+			return ld.Stop(ctx, id, 2, t0.Add(2*time.Minute))
+		},
+		"answer": func(ld *SessionDataLayer, id game.SessionID) error {
+			_, err := ld.Answer(ctx, id, 0, 1, t0.Add(2*time.Minute))
+			return err
+		},
+		"skip": func(ld *SessionDataLayer, id game.SessionID) error {
+			_, err := ld.Skip(ctx, id, 1, t0.Add(2*time.Minute))
+			return err
+		},
+		"answer of another user": func(ld *SessionDataLayer, id game.SessionID) error {
+			_, err := ld.Answer(ctx, id, 0, 2, t0.Add(2*time.Minute))
+			return err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			db := mocks.NewGameSessionsDB(t)
+			db.EXPECT().CreateSession(mock.Anything, 1, t0, mock.Anything, stored(generator.Options{})).Return(5, nil).Once()
+			db.EXPECT().FinishSession(mock.Anything, mock.MatchedBy(func(s *game.Session) bool {
+				return s.ID() == 5 && s.FinishTime().Equal(t0.Add(time.Minute))
+			}), game.FinishTimeout).Return(nil).Once()
+
+			ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+			s, err := ld.CreateSession(ctx, time.Minute, 1, generator.Options{}, t0)
+			assert.NoError(t, err)
+
+			err = call(ld, s.ID())
+			if strings.Contains(name, "another user") {
+				assert.ErrorIs(t, err, models.ErrForbidden)
+			} else {
+				assert.ErrorIs(t, err, game.ErrTimeIsLeft)
+			}
+			assert.ErrorIs(t, ld.Stop(ctx, s.ID(), 1, t0.Add(3*time.Minute)), game.ErrSessionNotFound)
+		})
+	}
+}
+
+func TestSessionDataLayerAdaptiveConfig(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 20, 12, 0, 0, 0, time.UTC)
+	opts := generator.Options{Difficulty: generator.Easy, Adaptive: true}
+
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 1, t0, mock.Anything, stored(opts)).Return(5, nil).Once()
+	db.EXPECT().InsertAnswer(mock.Anything, game.SessionID(5), mock.Anything).Return(nil).Once()
+
+	// the level changes after every answer
+	cfg := generator.AdaptiveConfig{TargetSuccessRate: 0.5, Window: 1}
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard), WithAdaptiveConfig(cfg))
+	s, err := ld.CreateSession(ctx, time.Minute, 1, opts, t0)
+	assert.NoError(t, err)
+
+	_, err = ld.Answer(ctx, s.ID(), s.CurrentExpression().Calculate(), 1, t0.Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, generator.Medium, s.Difficulty())
+}
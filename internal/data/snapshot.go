@@ -0,0 +1,231 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/generator"
+)
+
+// RestorePolicy tells how the time the server was down is counted for the restored sessions.
+type RestorePolicy string
+
+const (
+	// RestorePause stops the time of the sessions while the server is down.
+	RestorePause RestorePolicy = "pause"
+
+	// RestoreElapse counts the downtime, the sessions whose time is over are finished by timeout.
+	RestoreElapse RestorePolicy = "elapse"
+)
+
+var ErrUnknownRestorePolicy = errors.New("unknown restore policy")
+
+// ParseRestorePolicy parses a policy, the empty string means RestorePause.
+func ParseRestorePolicy(s string) (RestorePolicy, error) {
+	switch p := RestorePolicy(s); p {
+	case "":
+		return RestorePause, nil
+	case RestorePause, RestoreElapse:
+		return p, nil
+	}
+	return "", fmt.Errorf("%q: %w", s, ErrUnknownRestorePolicy)
+}
+
+type snapshot struct {
+	Time     time.Time    `json:"time"`
+	Sessions []game.State `json:"sessions"`
+}
+
+// WriteSnapshot writes the state of the active sessions.
+func (ld *SessionDataLayer) WriteSnapshot(w io.Writer, timeNow time.Time) error {
+	sessions, err := ld.store.All(context.Background())
+	if err != nil {
+		return fmt.Errorf("active sessions: %w", err)
+	}
+	return writeSnapshot(w, sessions, timeNow)
+}
+
+func writeSnapshot(w io.Writer, sessions []*game.Session, timeNow time.Time) error {
+	snap := snapshot{Time: timeNow, Sessions: make([]game.State, 0, len(sessions))}
+	for _, s := range sessions {
+		snap.Sessions = append(snap.Sessions, s.State())
+	}
+
+	if err := json.NewEncoder(w).Encode(snap); err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	return nil
+}
+
+// SaveSnapshot writes the state of the active sessions to the file.
+// The file is replaced atomically, so a crash leaves the previous snapshot intact.
+func (ld *SessionDataLayer) SaveSnapshot(path string, timeNow time.Time) error {
+	sessions, err := ld.store.All(context.Background())
+	if err != nil {
+		return fmt.Errorf("active sessions: %w", err)
+	}
+	return saveSnapshot(path, sessions, timeNow)
+}
+
+func saveSnapshot(path string, sessions []*game.Session, timeNow time.Time) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := writeSnapshot(f, sessions, timeNow); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close snapshot: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("replace snapshot: %w", err)
+	}
+	return nil
+}
+
+// Suspend stops accepting new sessions and saves the active ones to the file
+// instead of finishing them, so that they are restored after a restart.
+// The sessions are finished with the server shutdown reason if they cannot be saved.
+func (ld *SessionDataLayer) Suspend(ctx context.Context, path string, timeNow time.Time) error {
+	ld.closed.Store(true)
+	sessions, err := ld.store.Close(ctx)
+	if err != nil {
+		ld.logger.Errorf("close session store: %v", err)
+	}
+	if err := saveSnapshot(path, sessions, timeNow); err != nil {
+		for _, s := range sessions {
+			ld.shutdown(ctx, s, timeNow)
+		}
+		return err
+	}
+	return nil
+}
+
+// RunSnapshots saves the active sessions to the file every interval until ctx is done.
+func (ld *SessionDataLayer) RunSnapshots(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			if err := ld.SaveSnapshot(path, t); err != nil {
+				ld.logger.Errorf("snapshot: %v", err)
+			}
+		}
+	}
+}
+
+// ReadSnapshot restores the sessions written by WriteSnapshot and puts them into
+// the pool. The sessions finished after the snapshot was taken are skipped.
+// A session that cannot be restored is finished with the server shutdown reason
+// and the rest are restored anyway. It returns the number of restored sessions.
+func (ld *SessionDataLayer) ReadSnapshot(ctx context.Context, r io.Reader, policy RestorePolicy, timeNow time.Time) (int, error) {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return 0, fmt.Errorf("decode snapshot: %w", err)
+	}
+
+	restored := 0
+	for _, st := range snap.Sessions {
+		ok, err := ld.restoreSnapshot(ctx, st, snap.Time, policy, timeNow)
+		if err != nil {
+			ld.logger.Errorf("restore session %v: %v", st.ID, err)
+			continue
+		}
+		if ok {
+			restored++
+		}
+	}
+	return restored, nil
+}
+
+// restoreSnapshot puts the session of the state taken at snapTime into the pool.
+// It reports whether the session is active.
+func (ld *SessionDataLayer) restoreSnapshot(ctx context.Context, st game.State, snapTime time.Time, policy RestorePolicy, timeNow time.Time) (bool, error) {
+	finished, err := ld.db.IsSessionFinished(ctx, st.ID)
+	if err != nil {
+		// the session needs no generator to be finished
+		ld.shutdown(ctx, game.RestoreSession(st, nil), snapTime)
+		return false, fmt.Errorf("check session: %w", err)
+	}
+	if finished {
+		return false, nil
+	}
+
+	s, err := ld.Restore(st)
+	if err != nil {
+		ld.shutdown(ctx, game.RestoreSession(st, nil), snapTime)
+		return false, err
+	}
+	if policy == RestorePause {
+		s.Pause(timeNow.Sub(snapTime))
+	}
+
+	if !s.CheckTime(timeNow) {
+		ld.finish(ctx, s, game.FinishTimeout)
+		return false, nil
+	}
+	if err := ld.store.Put(ctx, s); err != nil {
+		ld.shutdown(ctx, s, timeNow)
+		return false, fmt.Errorf("set session active: %w", err)
+	}
+	return true, nil
+}
+
+// LoadSnapshot restores the sessions saved to the file by SaveSnapshot.
+// A missing file restores nothing.
+func (ld *SessionDataLayer) LoadSnapshot(ctx context.Context, path string, policy RestorePolicy, timeNow time.Time) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	return ld.ReadSnapshot(ctx, f, policy, timeNow)
+}
+
+// Restore creates the session of the state with the generator advanced
+// to the position it was at when the state was taken. The generator is
+// created with the no-repeat window of the session, not the current one.
+func (ld *SessionDataLayer) Restore(st game.State) (*game.Session, error) {
+	gen, err := ld.generators.New(st.GeneratorOptions(), st.Seed, ld.adaptive)
+	if err != nil {
+		return nil, fmt.Errorf("new generator: %w", err)
+	}
+	if a, ok := generator.As[*generator.AdaptiveGenerator](gen); ok && st.Adaptation != nil {
+		// the levels are switched by the answers, so replaying the generated
+		// expressions does not bring the generator back
+		if err := a.Restore(*st.Adaptation); err != nil {
+			return nil, fmt.Errorf("restore generator: %w", err)
+		}
+		if nr, ok := generator.As[*generator.NoRepeatGenerator](gen); ok && st.NoRepeat != nil {
+			if err := nr.Restore(*st.NoRepeat); err != nil {
+				return nil, fmt.Errorf("restore generator: %w", err)
+			}
+		}
+		return game.RestoreSession(st, gen), nil
+	}
+
+	for range st.Generated {
+		gen.Generate()
+	}
+
+	return game.RestoreSession(st, gen), nil
+}
@@ -0,0 +1,180 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/pelageech/matharena/internal/data/mocks"
+	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/game/math"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 12, 12, 0, 0, 0, time.UTC)
+
+	db := mocks.NewGameSessionsDB(t)
+	opts := generator.Options{Difficulty: generator.Medium}
+	db.EXPECT().CreateSession(mock.Anything, 1, t0, mock.Anything, stored(opts)).Return(5, nil)
+	db.EXPECT().InsertAnswer(mock.Anything, game.SessionID(5), mock.Anything).Return(nil)
+
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+	s, err := ld.CreateSession(ctx, time.Minute, 1, opts, t0)
+	assert.NoError(t, err)
+	_, err = ld.Answer(ctx, s.ID(), s.CurrentExpression().Calculate(), 1, t0.Add(time.Second))
+	assert.NoError(t, err)
+
+	b := &bytes.Buffer{}
+	assert.NoError(t, ld.WriteSnapshot(b, t0.Add(2*time.Second)))
+	snap := b.Bytes()
+
+	t.Run("pause", func(t *testing.T) {
+		restoredDB := mocks.NewGameSessionsDB(t)
+		restoredDB.EXPECT().IsSessionFinished(mock.Anything, game.SessionID(5)).Return(false, nil)
+
+		restored := NewSessionDataLayer(restoredDB, nil, log.New(io.Discard))
+		n, err := restored.ReadSnapshot(ctx, bytes.NewReader(snap), RestorePause, t0.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		r, err := restored.store.Get(ctx, s.ID(), t0.Add(time.Hour))
+		assert.NoError(t, err)
+
+		want := s.State()
+		want.LastUpdateExpression = want.LastUpdateExpression.Add(time.Hour - 2*time.Second)
+		assert.Equal(t, want, r.State())
+
+		// the restored generator continues the sequence
+		assert.NoError(t, s.Answer(s.CurrentExpression().Calculate(), t0.Add(3*time.Second)))
+		assert.NoError(t, r.Answer(r.CurrentExpression().Calculate(), t0.Add(time.Hour+time.Second)))
+		assert.Equal(t, s.CurrentExpression(), r.CurrentExpression())
+	})
+
+	t.Run("elapse", func(t *testing.T) {
+		restoredDB := mocks.NewGameSessionsDB(t)
+		restoredDB.EXPECT().IsSessionFinished(mock.Anything, game.SessionID(5)).Return(false, nil)
+		restoredDB.EXPECT().FinishSession(mock.Anything, mock.Anything, game.FinishTimeout).Return(nil).Once()
+
+		restored := NewSessionDataLayer(restoredDB, nil, log.New(io.Discard))
+		n, err := restored.ReadSnapshot(ctx, bytes.NewReader(snap), RestoreElapse, t0.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("finished", func(t *testing.T) {
+		restoredDB := mocks.NewGameSessionsDB(t)
+		restoredDB.EXPECT().IsSessionFinished(mock.Anything, game.SessionID(5)).Return(true, nil)
+
+		restored := NewSessionDataLayer(restoredDB, nil, log.New(io.Discard))
+		n, err := restored.ReadSnapshot(ctx, bytes.NewReader(snap), RestorePause, t0.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+	})
+}
+
+func TestReadSnapshotFailures(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 19, 12, 0, 0, 0, time.UTC)
+
+	st := game.State{
+		UserID:               1,
+		Expression:           math.AST{ExpressionInt: math.Num(1)},
+		Answer:               1,
+		TimeLeft:             time.Minute,
+		StartTime:            t0,
+		LastUpdateExpression: t0,
+	}
+	failedCheck, unknownFamily, restored := st, st, st
+	failedCheck.ID = 5
+	unknownFamily.ID, unknownFamily.Family = 6, "missing"
+	restored.ID = 7
+
+	b := &bytes.Buffer{}
+	assert.NoError(t, json.NewEncoder(b).Encode(snapshot{
+		Time:     t0.Add(10 * time.Second),
+		Sessions: []game.State{failedCheck, unknownFamily, restored},
+	}))
+
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().IsSessionFinished(mock.Anything, game.SessionID(5)).Return(false, errors.New("connection refused")).Once()
+	db.EXPECT().IsSessionFinished(mock.Anything, game.SessionID(6)).Return(false, nil).Once()
+	db.EXPECT().IsSessionFinished(mock.Anything, game.SessionID(7)).Return(false, nil).Once()
+	// the sessions which cannot be restored are stopped at the time of the snapshot
+	for _, id := range []game.SessionID{5, 6} {
+		db.EXPECT().FinishSession(mock.Anything, mock.MatchedBy(func(s *game.Session) bool {
+			return s.ID() == id && s.FinishTime().Equal(t0.Add(10*time.Second))
+		}), game.FinishShutdown).Return(nil).Once()
+	}
+
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+	n, err := ld.ReadSnapshot(ctx, b, RestorePause, t0.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = ld.store.Get(ctx, 7, t0.Add(time.Hour))
+	assert.NoError(t, err)
+}
+
+func TestRestoreAdaptive(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 19, 12, 0, 0, 0, time.UTC)
+	opts := generator.Options{Difficulty: generator.Easy, Adaptive: true}
+
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 1, t0, mock.Anything, stored(opts)).Return(5, nil)
+	db.EXPECT().InsertAnswer(mock.Anything, game.SessionID(5), mock.Anything).Return(nil)
+
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+	s, err := ld.CreateSession(ctx, time.Minute, 1, opts, t0)
+	assert.NoError(t, err)
+	for i := range generator.DefaultAdaptiveConfig.Window + 1 {
+		_, err = ld.Answer(ctx, s.ID(), s.CurrentExpression().Calculate(), 1, t0.Add(time.Duration(i+1)*time.Second))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, generator.Medium, s.Difficulty())
+
+	// the restored session keeps the level and the recent expressions and continues the sequence
+	r, err := ld.Restore(s.State())
+	assert.NoError(t, err)
+	assert.Equal(t, s.State(), r.State())
+	assert.Equal(t, generator.Medium, r.Difficulty())
+
+	timeNow := t0.Add(10 * time.Second)
+	assert.NoError(t, s.Answer(s.CurrentExpression().Calculate(), timeNow))
+	assert.NoError(t, r.Answer(r.CurrentExpression().Calculate(), timeNow))
+	assert.Equal(t, s.State(), r.State())
+}
+
+func TestRestoreNoRepeatWindow(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 20, 12, 0, 0, 0, time.UTC)
+
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 1, t0, mock.Anything, stored(generator.Options{})).Return(5, nil)
+
+	ld := NewSessionDataLayer(db, nil, log.New(io.Discard))
+	s, err := ld.CreateSession(ctx, time.Minute, 1, generator.Options{}, t0)
+	assert.NoError(t, err)
+
+	// the window of the session is kept by a server configured with another one
+	r, err := NewSessionDataLayer(mocks.NewGameSessionsDB(t), nil, log.New(io.Discard), WithNoRepeatWindow(0)).Restore(s.State())
+	assert.NoError(t, err)
+	assert.Equal(t, _defaultNoRepeatWindow, r.State().NoRepeatWindow)
+
+	for i := range 50 {
+		timeNow := t0.Add(time.Duration(i+1) * time.Millisecond)
+		assert.NoError(t, s.Answer(s.CurrentExpression().Calculate(), timeNow))
+		assert.NoError(t, r.Answer(r.CurrentExpression().Calculate(), timeNow))
+		assert.Equal(t, s.State(), r.State())
+	}
+}
@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/pelageech/matharena/internal/game"
+)
+
+// SessionStore keeps the active sessions.
+type SessionStore interface {
+	// Get returns the session. If the time of the session is over, the session
+	// is removed and returned with game.ErrTimeIsLeft.
+	Get(ctx context.Context, id game.SessionID, timeNow time.Time) (*game.Session, error)
+
+	// Put adds a new session. It returns game.ErrPoolClosed after Close.
+	Put(ctx context.Context, s *game.Session) error
+
+	// Update gets the session like Get, applies fn to it and stores the result
+	// even if fn fails, the error of fn is returned then. fn may be called
+	// again if the session was changed concurrently.
+	Update(ctx context.Context, id game.SessionID, timeNow time.Time, fn func(*game.Session) error) (*game.Session, error)
+
+	// Delete removes the session. It reports whether the session was there,
+	// so that only one of concurrent callers finishes it.
+	Delete(ctx context.Context, id game.SessionID) (bool, error)
+
+	// Expired removes the sessions whose time is over at timeNow and returns them.
+	// The removed sessions are returned along with the error of the others, so
+	// that they are finished anyway.
+	Expired(ctx context.Context, timeNow time.Time) ([]*game.Session, error)
+
+	// Close stops accepting new sessions and returns the sessions which are
+	// lost when the server stops, they are removed from the store.
+	Close(ctx context.Context) ([]*game.Session, error)
+
+	// All returns the sessions kept by the server.
+	All(ctx context.Context) ([]*game.Session, error)
+}
+
+// RestoreFunc creates a session from its state, see SessionDataLayer.Restore.
+type RestoreFunc func(game.State) (*game.Session, error)
+
+// MemoryStore keeps the sessions in the memory of the server.
+type MemoryStore struct {
+	pool *game.ActiveSessionsPool
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{pool: game.NewActiveSessionsPool()}
+}
+
+func (m *MemoryStore) Get(_ context.Context, id game.SessionID, timeNow time.Time) (*game.Session, error) {
+	return m.pool.Get(id, timeNow)
+}
+
+func (m *MemoryStore) Put(_ context.Context, s *game.Session) error {
+	return m.pool.Put(s)
+}
+
+// Update applies fn to the session in place, the session serialises concurrent updates itself
+// and rejects the moves made after it is stopped.
+func (m *MemoryStore) Update(_ context.Context, id game.SessionID, timeNow time.Time, fn func(*game.Session) error) (*game.Session, error) {
+	s, err := m.pool.Get(id, timeNow)
+	if err != nil {
+		return s, err
+	}
+	return s, fn(s)
+}
+
+func (m *MemoryStore) Delete(_ context.Context, id game.SessionID) (bool, error) {
+	return m.pool.Delete(id), nil
+}
+
+func (m *MemoryStore) Expired(_ context.Context, timeNow time.Time) ([]*game.Session, error) {
+	return m.pool.Expired(timeNow), nil
+}
+
+// Close returns all the sessions, they exist only in the memory of the server.
+func (m *MemoryStore) Close(context.Context) ([]*game.Session, error) {
+	return m.pool.Close(), nil
+}
+
+func (m *MemoryStore) All(context.Context) ([]*game.Session, error) {
+	return m.pool.All(), nil
+}
@@ -0,0 +1,37 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pelageech/matharena/internal/game"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	s := newTestSession(t, 1)
+	assert.NoError(t, store.Put(ctx, s))
+	assert.ErrorIs(t, store.Put(ctx, s), game.ErrSessionExists)
+
+	errFn := errors.New("fn")
+	got, err := store.Update(ctx, s.ID(), time.Now(), func(*game.Session) error { return errFn })
+	assert.ErrorIs(t, err, errFn)
+	assert.Same(t, s, got)
+
+	removed, err := store.Delete(ctx, s.ID())
+	assert.NoError(t, err)
+	assert.True(t, removed)
+
+	// only the first of concurrent removers finishes the session
+	removed, err = store.Delete(ctx, s.ID())
+	assert.NoError(t, err)
+	assert.False(t, removed)
+
+	_, err = store.Update(ctx, s.ID(), time.Now(), func(*game.Session) error { return nil })
+	assert.ErrorIs(t, err, game.ErrSessionNotFound)
+}
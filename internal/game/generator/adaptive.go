@@ -0,0 +1,184 @@
+package generator
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pelageech/matharena/internal/game/math"
+)
+
+// Observer is implemented by generators that adjust to the player.
+// A session reports every answer given in time to its generator if
+// the generator implements Observer.
+type Observer interface {
+	Observe(correct bool, latency time.Duration)
+}
+
+// AdaptiveConfig configures AdaptiveGenerator.
+type AdaptiveConfig struct {
+	// TargetSuccessRate is the share of successful answers the generator aims at.
+	TargetSuccessRate float64
+
+	// Tolerance is how far the success rate may deviate from the target
+	// before the level is changed.
+	Tolerance float64
+
+	// Window is the number of the latest answers the success rate is computed over.
+	Window int
+
+	// MaxLatency is the latency after which a correct answer is still considered
+	// a failure. Zero means that the latency is not taken into account.
+	MaxLatency time.Duration
+}
+
+// Validate checks that the generator can keep the success rate near the target.
+func (c AdaptiveConfig) Validate() error {
+	switch {
+	case c.TargetSuccessRate <= 0 || c.TargetSuccessRate >= 1:
+		return fmt.Errorf("target success rate %v is not in (0, 1): %w", c.TargetSuccessRate, ErrInvalidOptions)
+	case c.Tolerance < 0:
+		return fmt.Errorf("negative tolerance %v: %w", c.Tolerance, ErrInvalidOptions)
+	case c.Window < 1:
+		return fmt.Errorf("window %v is less than 1: %w", c.Window, ErrInvalidOptions)
+	case c.MaxLatency < 0:
+		return fmt.Errorf("negative max latency %v: %w", c.MaxLatency, ErrInvalidOptions)
+	}
+	return nil
+}
+
+var DefaultAdaptiveConfig = AdaptiveConfig{
+	TargetSuccessRate: 0.75,
+	Tolerance:         0.1,
+	Window:            6,
+	MaxLatency:        10 * time.Second,
+}
+
+// AdaptiveGenerator switches between generators of increasing complexity
+// to keep the player success rate near the target.
+type AdaptiveGenerator struct {
+	levels    []Generator
+	level     int
+	cfg       AdaptiveConfig
+	results   []bool
+	generated []int
+}
+
+// NewAdaptiveGenerator returns a generator which starts from levels[start].
+// Levels must be ordered by complexity.
+func NewAdaptiveGenerator(levels []Generator, start int, cfg AdaptiveConfig) *AdaptiveGenerator {
+	start = max(0, min(start, len(levels)-1))
+	return &AdaptiveGenerator{
+		levels:    levels,
+		level:     start,
+		cfg:       cfg,
+		results:   make([]bool, 0, cfg.Window),
+		generated: make([]int, len(levels)),
+	}
+}
+
+// NewAdaptive returns an adaptive generator switching between the Easy, Medium
+// and Hard generators and starting from the given difficulty.
+func NewAdaptive(start Difficulty, seed uint64, cfg AdaptiveConfig) (*AdaptiveGenerator, error) {
+	if start < Easy || start > Hard {
+		return nil, fmt.Errorf("%v: %w", start, ErrUnknownDifficulty)
+	}
+
+	levels := []Generator{
+		NewEasyGenerator(seed),
+		NewMediumGenerator(seed + 1),
+		NewHardGenerator(seed + 2),
+	}
+	return NewAdaptiveGenerator(levels, int(start), cfg), nil
+}
+
+func (g *AdaptiveGenerator) Generate() math.ExpressionInt {
+	g.generated[g.level]++
+	return g.levels[g.level].Generate()
+}
+
+// Difficulty returns the difficulty of the current level.
+func (g *AdaptiveGenerator) Difficulty() Difficulty {
+	return g.levels[g.level].Difficulty()
+}
+
+// Observe records the answer and changes the level once the success rate
+// over the window leaves the tolerance range.
+func (g *AdaptiveGenerator) Observe(correct bool, latency time.Duration) {
+	success := correct && (g.cfg.MaxLatency == 0 || latency <= g.cfg.MaxLatency)
+	g.results = append(g.results, success)
+	if len(g.results) < g.cfg.Window {
+		return
+	}
+
+	successful := 0
+	for _, r := range g.results {
+		if r {
+			successful++
+		}
+	}
+	rate := float64(successful) / float64(len(g.results))
+
+	switch {
+	case rate > g.cfg.TargetSuccessRate+g.cfg.Tolerance && g.level < len(g.levels)-1:
+		g.level++
+		g.results = g.results[:0]
+	case rate < g.cfg.TargetSuccessRate-g.cfg.Tolerance && g.level > 0:
+		g.level--
+		g.results = g.results[:0]
+	default:
+		g.results = append(g.results[:0], g.results[1:]...)
+	}
+}
+
+// AdaptiveState is the state of an AdaptiveGenerator which can be stored and restored later.
+type AdaptiveState struct {
+	// Level is the index of the current level.
+	Level int `json:"level"`
+
+	// Results are the successes of the answers in the current window.
+	Results []bool `json:"results"`
+
+	// Generated is the number of expressions generated by every level.
+	Generated []int `json:"generated"`
+}
+
+// State returns the state of the generator.
+func (g *AdaptiveGenerator) State() AdaptiveState {
+	return AdaptiveState{
+		Level:     g.level,
+		Results:   slices.Clone(g.results),
+		Generated: slices.Clone(g.generated),
+	}
+}
+
+// Restore advances the levels of the new generator to the state, so that it
+// continues the sequence of the generator the state was taken from.
+func (g *AdaptiveGenerator) Restore(st AdaptiveState) error {
+	if len(st.Generated) != len(g.levels) || st.Level < 0 || st.Level >= len(g.levels) {
+		return fmt.Errorf("level %v of %v levels: %w", st.Level, len(st.Generated), ErrInvalidState)
+	}
+	for i, n := range st.Generated {
+		for ; g.generated[i] < n; g.generated[i]++ {
+			g.levels[i].Generate()
+		}
+	}
+	g.level = st.Level
+	g.results = append(g.results[:0], st.Results...)
+	return nil
+}
+
+// As returns the generator of type T among g and the generators it wraps, if any.
+func As[T Generator](g Generator) (T, bool) {
+	for {
+		if t, ok := g.(T); ok {
+			return t, true
+		}
+		w, ok := g.(interface{ Unwrap() Generator })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		g = w.Unwrap()
+	}
+}
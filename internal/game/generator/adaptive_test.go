@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveGenerator(t *testing.T) {
+	g, err := NewAdaptive(Medium, 1, AdaptiveConfig{
+		TargetSuccessRate: 0.5,
+		Tolerance:         0.2,
+		Window:            4,
+		MaxLatency:        time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	observe := func(n int, correct bool, latency time.Duration) {
+		for range n {
+			g.Generate()
+			g.Observe(correct, latency)
+		}
+	}
+
+	observe(3, true, time.Millisecond)
+	if g.Difficulty() != Medium {
+		t.Fatalf("the level changed before the window is full: %v", g.Difficulty())
+	}
+
+	observe(1, true, time.Millisecond)
+	if g.Difficulty() != Hard {
+		t.Fatalf("expected: %v, got: %v", Hard, g.Difficulty())
+	}
+
+	observe(8, true, time.Millisecond)
+	if g.Difficulty() != Hard {
+		t.Fatalf("expected: %v, got: %v", Hard, g.Difficulty())
+	}
+
+	// slow answers are failures even if they are correct
+	observe(4, true, 2*time.Second)
+	if g.Difficulty() != Medium {
+		t.Fatalf("expected: %v, got: %v", Medium, g.Difficulty())
+	}
+
+	// the rate stays within the tolerance
+	for range 4 {
+		observe(1, true, time.Millisecond)
+		observe(1, false, time.Millisecond)
+	}
+	if g.Difficulty() != Medium {
+		t.Fatalf("expected: %v, got: %v", Medium, g.Difficulty())
+	}
+
+	observe(8, false, time.Millisecond)
+	if g.Difficulty() != Easy {
+		t.Fatalf("expected: %v, got: %v", Easy, g.Difficulty())
+	}
+}
+
+func TestAdaptiveGeneratorRestore(t *testing.T) {
+	cfg := AdaptiveConfig{TargetSuccessRate: 0.5, Tolerance: 0.2, Window: 2}
+	g, err := NewAdaptive(Easy, 1, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range 3 {
+		g.Generate()
+		g.Observe(true, 0)
+	}
+
+	restored, err := NewAdaptive(Easy, 1, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := restored.Restore(g.State()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restored.Difficulty() != Medium {
+		t.Fatalf("expected: %v, got: %v", Medium, restored.Difficulty())
+	}
+
+	// the restored generator continues the sequence and the window
+	for range 4 {
+		if want, got := g.Generate(), restored.Generate(); !reflect.DeepEqual(want, got) {
+			t.Fatalf("expected: %s, got: %s", want.Marshal(), got.Marshal())
+		}
+		g.Observe(true, 0)
+		restored.Observe(true, 0)
+	}
+	if g.Difficulty() != Hard || restored.Difficulty() != Hard {
+		t.Fatalf("expected: %v, got: %v and %v", Hard, g.Difficulty(), restored.Difficulty())
+	}
+
+	if err := restored.Restore(AdaptiveState{Level: 3, Generated: make([]int, 3)}); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expected: %v, got: %v", ErrInvalidState, err)
+	}
+}
+
+func TestAdaptiveConfigValidate(t *testing.T) {
+	if err := DefaultAdaptiveConfig.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, cfg := range []AdaptiveConfig{
+		{TargetSuccessRate: 0, Window: 1},
+		{TargetSuccessRate: 1, Window: 1},
+		{TargetSuccessRate: 0.5, Tolerance: -0.1, Window: 1},
+		{TargetSuccessRate: 0.5, Window: 0},
+		{TargetSuccessRate: 0.5, Window: 1, MaxLatency: -time.Second},
+	} {
+		if err := cfg.Validate(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("%+v: expected: %v, got: %v", cfg, ErrInvalidOptions, err)
+		}
+	}
+}
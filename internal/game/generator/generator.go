@@ -1,7 +1,10 @@
 package generator
 
 import (
+	"errors"
+	"fmt"
 	"math/rand/v2"
+	"strings"
 
 	"github.com/pelageech/matharena/internal/game/math"
 )
@@ -26,26 +29,199 @@ func (d Difficulty) String() string {
 	return "Unknown"
 }
 
+var (
+	ErrUnknownDifficulty = errors.New("unknown difficulty")
+	ErrInvalidOptions    = errors.New("invalid generator options")
+	ErrInvalidState      = errors.New("invalid generator state")
+)
+
+// ParseDifficulty parses a difficulty name case-insensitively.
+func ParseDifficulty(s string) (Difficulty, error) {
+	for _, d := range []Difficulty{Easy, Medium, Hard} {
+		if strings.EqualFold(s, d.String()) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("%q: %w", s, ErrUnknownDifficulty)
+}
+
 //go:generate mockery --name Generator --output=./ --filename=mocks/generator.go --with-expecter
 type Generator interface {
 	Generate() math.ExpressionInt
 	Difficulty() Difficulty
 }
 
-type EasyGenerator struct{}
+// New returns a generator for the given difficulty. Generators created
+// with the same difficulty and seed produce the same sequence of expressions.
+func New(d Difficulty, seed uint64) (Generator, error) {
+	switch d {
+	case Easy:
+		return NewEasyGenerator(seed), nil
+	case Medium:
+		return NewMediumGenerator(seed), nil
+	case Hard:
+		return NewHardGenerator(seed), nil
+	}
+	return nil, fmt.Errorf("%v: %w", d, ErrUnknownDifficulty)
+}
 
-func (g *EasyGenerator) Generate() math.ExpressionInt {
-	n := 2 + rand.N(2)
+// Options describe the generator of a session.
+type Options struct {
+	Difficulty Difficulty
+
+	// Adaptive makes the generator follow the player performance
+	// starting from Difficulty.
+	Adaptive bool
+
+	// Family is the name of a puzzle family compiled from a template.
+	// Families are resolved by Catalog and ignore Difficulty.
+	Family string
+
+	// NoRepeatWindow is the number of recent expressions and answers which
+	// must not repeat, see NoRepeatGenerator. It is set by the server.
+	NoRepeatWindow int
+}
+
+// New returns a generator for a built-in difficulty described by the options.
+// An adaptive generator is configured with cfg.
+func (o Options) New(seed uint64, cfg AdaptiveConfig) (Generator, error) {
+	if o.Family != "" {
+		return nil, fmt.Errorf("%q: use Catalog to create generators of families: %w", o.Family, ErrInvalidOptions)
+	}
+	if o.Adaptive {
+		return NewAdaptive(o.Difficulty, seed, cfg)
+	}
+	return New(o.Difficulty, seed)
+}
+
+// NewSeed returns a random seed for a generator.
+func NewSeed() uint64 {
+	return rand.Uint64()
+}
 
-	sum := math.Sum{}
+// source is a source of random numbers of a generator.
+// The zero value uses the global source of math/rand/v2.
+type source struct {
+	rng *rand.Rand
+}
 
-	for range n {
-		sum = append(sum, math.Num(rand.N(50)))
+func newSource(seed uint64) source {
+	return source{rng: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// IntN returns a random number in [0, n).
+func (s source) IntN(n int) int {
+	if s.rng == nil {
+		return rand.IntN(n)
 	}
+	return s.rng.IntN(n)
+}
 
-	return sum
+// Perm returns a random permutation of [0, n).
+func (s source) Perm(n int) []int {
+	if s.rng == nil {
+		return rand.Perm(n)
+	}
+	return s.rng.Perm(n)
+}
+
+// _maxAttempts limits how many trees a generator builds before giving up
+// on finding one that can be evaluated.
+const _maxAttempts = 1000
+
+// valid calls generate until it returns an expression which can be evaluated
+// without errors and whose answer does not exceed limit by absolute value.
+// Zero limit means no limit. The last generated expression is returned
+// if none of the attempts succeeded.
+func valid(generate func() math.ExpressionInt, limit int) math.ExpressionInt {
+	var e math.ExpressionInt
+	for range _maxAttempts {
+		e = generate()
+		answer, err := e.Evaluate()
+		if err == nil && (limit == 0 || -limit <= answer && answer <= limit) {
+			return e
+		}
+	}
+	return e
+}
+
+// EasyGenerator builds sums of two or three small numbers.
+// The zero value uses the global random source.
+type EasyGenerator struct {
+	src source
+}
+
+func NewEasyGenerator(seed uint64) *EasyGenerator {
+	return &EasyGenerator{src: newSource(seed)}
+}
+
+func (g *EasyGenerator) Generate() math.ExpressionInt {
+	return valid(func() math.ExpressionInt {
+		n := 2 + g.src.IntN(2)
+
+		sum := math.Sum{}
+
+		for range n {
+			sum = append(sum, math.Num(g.src.IntN(50)))
+		}
+
+		return sum
+	}, 0)
 }
 
 func (g *EasyGenerator) Difficulty() Difficulty {
 	return Easy
 }
+
+// MediumGenerator builds two-level trees of additions, subtractions and multiplications.
+// The zero value uses the global random source.
+type MediumGenerator struct {
+	src source
+}
+
+func NewMediumGenerator(seed uint64) *MediumGenerator {
+	return &MediumGenerator{src: newSource(seed)}
+}
+
+func (g *MediumGenerator) Generate() math.ExpressionInt {
+	t := tree{
+		src:     g.src,
+		ops:     []operator{opAdd, opSub, opMul},
+		leafMin: 1,
+		leafMax: 30,
+	}
+	return valid(func() math.ExpressionInt {
+		return t.build(2)
+	}, 500)
+}
+
+func (g *MediumGenerator) Difficulty() Difficulty {
+	return Medium
+}
+
+// HardGenerator builds deeper trees with all the operators including
+// exact divisions, small powers and factorials.
+// The zero value uses the global random source.
+type HardGenerator struct {
+	src source
+}
+
+func NewHardGenerator(seed uint64) *HardGenerator {
+	return &HardGenerator{src: newSource(seed)}
+}
+
+func (g *HardGenerator) Generate() math.ExpressionInt {
+	t := tree{
+		src:     g.src,
+		ops:     []operator{opAdd, opSub, opMul, opMul, opDiv, opPow, opFact},
+		leafMin: 1,
+		leafMax: 99,
+	}
+	return valid(func() math.ExpressionInt {
+		return t.build(3)
+	}, 5000)
+}
+
+func (g *HardGenerator) Difficulty() Difficulty {
+	return Hard
+}
@@ -0,0 +1,67 @@
+package generator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		difficulty Difficulty
+		limit      int
+	}{
+		{Easy, 150},
+		{Medium, 500},
+		{Hard, 5000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.difficulty.String(), func(t *testing.T) {
+			g, err := New(tt.difficulty, NewSeed())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if g.Difficulty() != tt.difficulty {
+				t.Fatalf("expected: %v, got: %v", tt.difficulty, g.Difficulty())
+			}
+
+			for range 1000 {
+				e := g.Generate()
+				answer, err := e.Evaluate()
+				if err != nil {
+					t.Fatalf("%s: %v", e.Marshal(), err)
+				}
+				if answer < -tt.limit || answer > tt.limit {
+					t.Fatalf("%s = %d: answer is out of range", e.Marshal(), answer)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDifficulty(t *testing.T) {
+	d, err := ParseDifficulty("hard")
+	if err != nil || d != Hard {
+		t.Fatalf("expected: %v, got: %v, %v", Hard, d, err)
+	}
+
+	if _, err := ParseDifficulty("nightmare"); !errors.Is(err, ErrUnknownDifficulty) {
+		t.Fatalf("expected: %v, got: %v", ErrUnknownDifficulty, err)
+	}
+}
+
+func TestSeededGenerators(t *testing.T) {
+	for _, d := range []Difficulty{Easy, Medium, Hard} {
+		t.Run(d.String(), func(t *testing.T) {
+			a, _ := New(d, 42)
+			b, _ := New(d, 42)
+
+			for range 100 {
+				if x, y := a.Generate(), b.Generate(); !reflect.DeepEqual(x, y) {
+					t.Fatalf("expected: %s, got: %s", x.Marshal(), y.Marshal())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package generator
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelageech/matharena/internal/game/math"
+)
+
+// NoRepeatGenerator wraps a generator and rejects expressions that repeat
+// one of the recent expressions or answers. Expressions are compared up to
+// the order of the operands of sums and products, so 5+3 repeats 3+5.
+type NoRepeatGenerator struct {
+	gen     Generator
+	window  int
+	keys    []string
+	answers []int
+}
+
+// NewNoRepeatGenerator returns a generator which remembers the last window
+// expressions and answers of gen.
+func NewNoRepeatGenerator(gen Generator, window int) *NoRepeatGenerator {
+	window = max(window, 0)
+	return &NoRepeatGenerator{
+		gen:     gen,
+		window:  window,
+		keys:    make([]string, 0, window),
+		answers: make([]int, 0, window),
+	}
+}
+
+// Generate asks the wrapped generator for expressions until it returns a fresh one.
+// The last expression is returned if no fresh expression was found.
+func (g *NoRepeatGenerator) Generate() math.ExpressionInt {
+	var e math.ExpressionInt
+	for range _maxAttempts {
+		e = g.gen.Generate()
+		answer, err := e.Evaluate()
+		if err != nil {
+			continue
+		}
+
+		key := canonical(e)
+		if !slices.Contains(g.keys, key) && !slices.Contains(g.answers, answer) {
+			g.remember(key, answer)
+			return e
+		}
+	}
+
+	return e
+}
+
+func (g *NoRepeatGenerator) remember(key string, answer int) {
+	if g.window == 0 {
+		return
+	}
+	if len(g.keys) == g.window {
+		g.keys = append(g.keys[:0], g.keys[1:]...)
+		g.answers = append(g.answers[:0], g.answers[1:]...)
+	}
+	g.keys = append(g.keys, key)
+	g.answers = append(g.answers, answer)
+}
+
+// NoRepeatState is the state of a NoRepeatGenerator which can be stored and restored later.
+type NoRepeatState struct {
+	// Keys are the canonical forms of the remembered expressions.
+	Keys    []string `json:"keys"`
+	Answers []int    `json:"answers"`
+}
+
+// State returns the remembered expressions and answers.
+func (g *NoRepeatGenerator) State() NoRepeatState {
+	return NoRepeatState{Keys: slices.Clone(g.keys), Answers: slices.Clone(g.answers)}
+}
+
+// Restore replaces the remembered expressions and answers with the ones of the state.
+func (g *NoRepeatGenerator) Restore(st NoRepeatState) error {
+	if len(st.Keys) != len(st.Answers) || len(st.Keys) > g.window {
+		return fmt.Errorf("%v keys and %v answers of window %v: %w", len(st.Keys), len(st.Answers), g.window, ErrInvalidState)
+	}
+	g.keys = append(g.keys[:0], st.Keys...)
+	g.answers = append(g.answers[:0], st.Answers...)
+	return nil
+}
+
+// Unwrap returns the wrapped generator.
+func (g *NoRepeatGenerator) Unwrap() Generator {
+	return g.gen
+}
+
+func (g *NoRepeatGenerator) Difficulty() Difficulty {
+	return g.gen.Difficulty()
+}
+
+// Observe passes the answer to the wrapped generator if it is an Observer.
+func (g *NoRepeatGenerator) Observe(correct bool, latency time.Duration) {
+	if o, ok := g.gen.(Observer); ok {
+		o.Observe(correct, latency)
+	}
+}
+
+// canonical returns a key which is the same for expressions that differ only
+// in the order of the operands of sums and products.
+func canonical(e math.ExpressionInt) string {
+	b := &strings.Builder{}
+	writeCanonical(b, math.Normalize(e))
+	return b.String()
+}
+
+func writeCanonical(b *strings.Builder, e math.ExpressionInt) {
+	var op string
+	switch e.(type) {
+	case math.Num:
+		b.WriteString(strconv.Itoa(e.Calculate()))
+		return
+	case math.Sum:
+		op = math.OpSum
+	case math.Product:
+		op = math.OpProduct
+	case math.Difference:
+		op = math.OpDifference
+	case math.Quotient:
+		op = math.OpQuotient
+	case math.Power:
+		op = math.OpPower
+	case math.Fact:
+		op = math.OpFact
+	}
+
+	operands := math.Operands(e)
+	keys := make([]string, 0, len(operands))
+	for _, x := range operands {
+		sb := &strings.Builder{}
+		writeCanonical(sb, x)
+		keys = append(keys, sb.String())
+	}
+	if op == math.OpSum || op == math.OpProduct {
+		slices.Sort(keys)
+	}
+
+	b.WriteString(op)
+	b.WriteByte('(')
+	b.WriteString(strings.Join(keys, ","))
+	b.WriteByte(')')
+}
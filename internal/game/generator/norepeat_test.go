@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pelageech/matharena/internal/game/math"
+)
+
+// sequence generates the given expressions in a loop.
+type sequence struct {
+	exprs []math.ExpressionInt
+	i     int
+}
+
+func (s *sequence) Generate() math.ExpressionInt {
+	e := s.exprs[s.i%len(s.exprs)]
+	s.i++
+	return e
+}
+
+func (s *sequence) Difficulty() Difficulty {
+	return Easy
+}
+
+func TestNoRepeatGenerator(t *testing.T) {
+	seq := &sequence{exprs: []math.ExpressionInt{
+		math.Sum{math.Num(3), math.Num(5)},
+		math.Sum{math.Num(5), math.Num(3)},
+		math.Product{math.Num(2), math.Num(4)},
+		math.Difference{Minuend: math.Num(10), Subtrahend: math.Num(1)},
+		math.Sum{math.Num(-1), math.Num(10)},
+		math.Sum{math.Num(2), math.Num(5)},
+	}}
+	g := NewNoRepeatGenerator(seq, 2)
+
+	want := []string{"3+5", "10-1", "2+5", "3+5"}
+	for _, w := range want {
+		if got := string(g.Generate().Marshal()); got != w {
+			t.Fatalf("expected: `%v`, got: `%v`", w, got)
+		}
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	a := math.Sum{math.Num(1), math.Product{math.Num(2), math.Num(3)}}
+	b := math.Sum{math.Product{math.Num(3), math.Num(2)}, math.Num(1)}
+	if canonical(a) != canonical(b) {
+		t.Fatalf("%s and %s must be equivalent", a.Marshal(), b.Marshal())
+	}
+
+	c := math.Difference{Minuend: math.Num(2), Subtrahend: math.Fact{Fact: math.Num(3)}}
+	d := math.Difference{Minuend: math.Fact{Fact: math.Num(3)}, Subtrahend: math.Num(2)}
+	if canonical(c) == canonical(d) {
+		t.Fatalf("%s and %s must not be equivalent", c.Marshal(), d.Marshal())
+	}
+}
+
+func TestNoRepeatGeneratorRestore(t *testing.T) {
+	exprs := []math.ExpressionInt{
+		math.Sum{math.Num(3), math.Num(5)},
+		math.Product{math.Num(2), math.Num(3)},
+		math.Sum{math.Num(5), math.Num(3)},
+		math.Sum{math.Num(2), math.Num(5)},
+	}
+	g := NewNoRepeatGenerator(&sequence{exprs: exprs}, 2)
+	g.Generate()
+	g.Generate()
+
+	// the restored generator remembers 3+5 and 2*3 without replaying them
+	restored := NewNoRepeatGenerator(&sequence{exprs: exprs, i: 2}, 2)
+	if err := restored.Restore(g.State()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(restored.Generate().Marshal()); got != "2+5" {
+		t.Fatalf("expected: `2+5`, got: `%v`", got)
+	}
+
+	if err := restored.Restore(NoRepeatState{Keys: []string{"a", "b", "c"}, Answers: []int{1, 2, 3}}); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expected: %v, got: %v", ErrInvalidState, err)
+	}
+}
@@ -0,0 +1,360 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelageech/matharena/internal/game/math"
+)
+
+var (
+	ErrInvalidTemplate = errors.New("invalid puzzle template")
+	ErrUnknownFamily   = errors.New("unknown puzzle family")
+)
+
+// Range is an inclusive range of integers.
+type Range struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+func (r Range) contains(i int) bool {
+	return r.Min <= i && i <= r.Max
+}
+
+// Template declares a family of puzzles, e.g.
+//
+//	{
+//	  "name": "products",
+//	  "difficulty": "medium",
+//	  "shapes": ["{a}*{b}+{c}", "{a}*({b}-{c})"],
+//	  "operands": {"a": {"min": 2, "max": 9}},
+//	  "default_operand": {"min": 1, "max": 20},
+//	  "operators": ["+", "-", "*"],
+//	  "answer": {"min": 0, "max": 200},
+//	  "no_negative_intermediates": true
+//	}
+//
+// A shape is an infix expression in which {name} placeholders are replaced
+// with random numbers from the operand ranges. The same placeholder gets
+// the same number within one puzzle. If there are no shapes, random trees
+// of the given depth are built from the operators and the default operand range.
+type Template struct {
+	Name string `json:"name"`
+
+	// Difficulty is reported by the generators of the family.
+	Difficulty string `json:"difficulty"`
+
+	Shapes         []string         `json:"shapes"`
+	Operands       map[string]Range `json:"operands"`
+	DefaultOperand *Range           `json:"default_operand"`
+
+	// Operators lists the operators allowed in the puzzles out of + - * / ^ !.
+	Operators []string `json:"operators"`
+	Depth     int      `json:"depth"`
+
+	// Answer bounds the answers of the puzzles.
+	Answer *Range `json:"answer"`
+
+	// NoNegativeIntermediates rejects puzzles in which any subexpression
+	// or a running total of a sum is negative.
+	NoNegativeIntermediates bool `json:"no_negative_intermediates"`
+}
+
+// LoadTemplates reads a JSON array of templates.
+func LoadTemplates(r io.Reader) ([]Template, error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+
+	var templates []Template
+	if err := d.Decode(&templates); err != nil {
+		return nil, fmt.Errorf("decode templates: %w", err)
+	}
+	return templates, nil
+}
+
+var _placeholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+var _operators = map[string]operator{
+	"+": opAdd,
+	"-": opSub,
+	"*": opMul,
+	"/": opDiv,
+	"^": opPow,
+	"!": opFact,
+}
+
+// Family is a compiled template.
+type Family struct {
+	name       string
+	difficulty Difficulty
+	shapes     []string
+	operands   map[string]Range
+	tree       tree
+	depth      int
+	answer     *Range
+	nonNeg     bool
+}
+
+// Compile validates the template and checks that its constraints can be satisfied.
+func Compile(t Template) (*Family, error) {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%q: %s: %w", t.Name, fmt.Sprintf(format, args...), ErrInvalidTemplate)
+	}
+
+	if t.Name == "" {
+		return nil, invalid("name is required")
+	}
+
+	d, err := ParseDifficulty(t.Difficulty)
+	if err != nil {
+		return nil, invalid("%v", err)
+	}
+
+	f := &Family{
+		name:       t.Name,
+		difficulty: d,
+		shapes:     t.Shapes,
+		operands:   make(map[string]Range),
+		depth:      t.Depth,
+		answer:     t.Answer,
+		nonNeg:     t.NoNegativeIntermediates,
+	}
+
+	allowed := make(map[string]bool)
+	for _, op := range t.Operators {
+		o, ok := _operators[op]
+		if !ok {
+			return nil, invalid("unknown operator %q", op)
+		}
+		allowed[op] = true
+		f.tree.ops = append(f.tree.ops, o)
+	}
+
+	for name, r := range t.Operands {
+		if r.Min > r.Max {
+			return nil, invalid("operand %s: min is greater than max", name)
+		}
+		f.operands[name] = r
+	}
+	if r := t.DefaultOperand; r != nil {
+		if r.Min > r.Max {
+			return nil, invalid("default operand: min is greater than max")
+		}
+		f.tree.leafMin, f.tree.leafMax = r.Min, r.Max
+	}
+	if r := t.Answer; r != nil && r.Min > r.Max {
+		return nil, invalid("answer: min is greater than max")
+	}
+
+	switch {
+	case len(t.Shapes) == 0 && len(t.Operators) == 0:
+		return nil, invalid("either shapes or operators are required")
+	case len(t.Shapes) == 0 && t.DefaultOperand == nil:
+		return nil, invalid("default operand is required to build random trees")
+	case len(t.Shapes) == 0 && t.Depth < 1:
+		return nil, invalid("depth must be positive")
+	}
+
+	for _, shape := range t.Shapes {
+		for _, m := range _placeholder.FindAllStringSubmatch(shape, -1) {
+			if _, ok := f.operands[m[1]]; ok {
+				continue
+			}
+			if t.DefaultOperand == nil {
+				return nil, invalid("shape %q: no range for operand %s", shape, m[1])
+			}
+			f.operands[m[1]] = *t.DefaultOperand
+		}
+
+		bare := _placeholder.ReplaceAllString(shape, "1")
+		if _, err := math.Parse(bare); err != nil {
+			return nil, invalid("shape %q: %v", shape, err)
+		}
+		if len(allowed) == 0 {
+			continue
+		}
+		for _, c := range bare {
+			if op := string(c); strings.ContainsRune("+-*/^!", c) && !allowed[op] {
+				return nil, invalid("shape %q: operator %s is not allowed", shape, op)
+			}
+		}
+	}
+
+	g := f.New(0)
+	if _, ok := g.generate(); !ok {
+		return nil, invalid("no puzzle satisfies the constraints in %d attempts", _maxAttempts)
+	}
+
+	return f, nil
+}
+
+func (f *Family) Name() string {
+	return f.name
+}
+
+// New returns a generator of puzzles of the family.
+func (f *Family) New(seed uint64) *TemplateGenerator {
+	return &TemplateGenerator{family: f, src: newSource(seed)}
+}
+
+// TemplateGenerator generates puzzles of a Family.
+type TemplateGenerator struct {
+	family *Family
+	src    source
+}
+
+func (g *TemplateGenerator) Generate() math.ExpressionInt {
+	e, _ := g.generate()
+	return e
+}
+
+func (g *TemplateGenerator) Difficulty() Difficulty {
+	return g.family.difficulty
+}
+
+// generate builds candidates until one of them satisfies the constraints of the family.
+// The last candidate is returned with false if none of the attempts succeeded.
+func (g *TemplateGenerator) generate() (math.ExpressionInt, bool) {
+	var e math.ExpressionInt = math.Num(0)
+	for range _maxAttempts {
+		candidate, err := g.candidate()
+		if err != nil {
+			continue
+		}
+		e = candidate
+		if g.family.accepts(e) {
+			return e, true
+		}
+	}
+	return e, false
+}
+
+func (g *TemplateGenerator) candidate() (math.ExpressionInt, error) {
+	f := g.family
+	if len(f.shapes) == 0 {
+		t := f.tree
+		t.src = g.src
+		return t.build(f.depth), nil
+	}
+
+	values := make(map[string]string)
+	shape := _placeholder.ReplaceAllStringFunc(f.shapes[g.src.IntN(len(f.shapes))], func(p string) string {
+		name := p[1 : len(p)-1]
+		if v, ok := values[name]; ok {
+			return v
+		}
+
+		r := f.operands[name]
+		i := r.Min + g.src.IntN(r.Max-r.Min+1)
+		v := strconv.Itoa(i)
+		if i < 0 {
+			v = "(" + v + ")"
+		}
+		values[name] = v
+		return v
+	})
+
+	return math.Parse(shape)
+}
+
+func (f *Family) accepts(e math.ExpressionInt) bool {
+	answer, err := e.Evaluate()
+	if err != nil {
+		return false
+	}
+	if f.answer != nil && !f.answer.contains(answer) {
+		return false
+	}
+	return !f.nonNeg || nonNegative(e)
+}
+
+// nonNegative reports whether all the subexpressions of e and all the running
+// totals of its sums are non-negative. A negative number in a sum which is not
+// its first term is written as a subtraction, so only the total is checked for it.
+func nonNegative(e math.ExpressionInt) bool {
+	v, err := e.Evaluate()
+	if err != nil || v < 0 {
+		return false
+	}
+
+	if s, ok := e.(math.Sum); ok {
+		total := 0
+		for i, x := range s {
+			if n, ok := x.(math.Num); !ok || n >= 0 || i == 0 {
+				if !nonNegative(x) {
+					return false
+				}
+			}
+			total += x.Calculate()
+			if total < 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, x := range math.Operands(e) {
+		if !nonNegative(x) {
+			return false
+		}
+	}
+	return true
+}
+
+// Catalog creates session generators for the built-in difficulties and
+// for the puzzle families compiled from templates.
+type Catalog struct {
+	families map[string]*Family
+}
+
+// NewCatalog compiles the templates. Template names must be unique.
+func NewCatalog(templates []Template) (*Catalog, error) {
+	c := &Catalog{families: make(map[string]*Family, len(templates))}
+	for _, t := range templates {
+		f, err := Compile(t)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := c.families[f.name]; ok {
+			return nil, fmt.Errorf("%q: duplicate name: %w", f.name, ErrInvalidTemplate)
+		}
+		c.families[f.name] = f
+	}
+	return c, nil
+}
+
+// New returns a generator described by the options, an adaptive generator
+// is configured with cfg. A nil catalog knows only the built-in difficulties.
+// The generator is wrapped with a NoRepeatGenerator of o.NoRepeatWindow,
+// so that the options and the seed reproduce the same sequence.
+func (c *Catalog) New(o Options, seed uint64, cfg AdaptiveConfig) (Generator, error) {
+	gen, err := c.new(o, seed, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewNoRepeatGenerator(gen, o.NoRepeatWindow), nil
+}
+
+func (c *Catalog) new(o Options, seed uint64, cfg AdaptiveConfig) (Generator, error) {
+	if o.Family == "" {
+		return o.New(seed, cfg)
+	}
+	if o.Adaptive {
+		return nil, fmt.Errorf("%q: puzzle families cannot be adaptive: %w", o.Family, ErrInvalidOptions)
+	}
+
+	var f *Family
+	if c != nil {
+		f = c.families[o.Family]
+	}
+	if f == nil {
+		return nil, fmt.Errorf("%q: %w", o.Family, ErrUnknownFamily)
+	}
+	return f.New(seed), nil
+}
@@ -0,0 +1,134 @@
+package generator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pelageech/matharena/internal/game/math"
+)
+
+const _templates = `[
+	{
+		"name": "products",
+		"difficulty": "medium",
+		"shapes": ["{a}*{b}-{c}", "{a}*({b}-{c})"],
+		"operands": {"a": {"min": 2, "max": 9}},
+		"default_operand": {"min": 1, "max": 20},
+		"operators": ["-", "*"],
+		"answer": {"min": 0, "max": 200},
+		"no_negative_intermediates": true
+	},
+	{
+		"name": "squares",
+		"difficulty": "hard",
+		"shapes": ["{x}^2-{y}^2"],
+		"operands": {"x": {"min": -9, "max": 9}, "y": {"min": 1, "max": 9}}
+	},
+	{
+		"name": "random",
+		"difficulty": "easy",
+		"operators": ["+", "-"],
+		"depth": 2,
+		"default_operand": {"min": 1, "max": 9},
+		"answer": {"min": 0, "max": 20}
+	}
+]`
+
+func TestCatalog(t *testing.T) {
+	templates, err := LoadTemplates(strings.NewReader(_templates))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c, err := NewCatalog(templates)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		family     string
+		difficulty Difficulty
+		answer     Range
+		nonNeg     bool
+	}{
+		{"products", Medium, Range{0, 200}, true},
+		{"squares", Hard, Range{-81, 80}, false},
+		{"random", Easy, Range{0, 20}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.family, func(t *testing.T) {
+			g, err := c.New(Options{Family: tt.family}, 1, DefaultAdaptiveConfig)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if g.Difficulty() != tt.difficulty {
+				t.Fatalf("expected: %v, got: %v", tt.difficulty, g.Difficulty())
+			}
+
+			for range 500 {
+				e := g.Generate()
+				answer, err := e.Evaluate()
+				if err != nil {
+					t.Fatalf("%s: %v", e.Marshal(), err)
+				}
+				if !tt.answer.contains(answer) {
+					t.Fatalf("%s = %d: answer is out of range", e.Marshal(), answer)
+				}
+				if tt.nonNeg && !nonNegative(e) {
+					t.Fatalf("%s: negative intermediate", e.Marshal())
+				}
+			}
+		})
+	}
+
+	if _, err := c.New(Options{Family: "unknown"}, 1, DefaultAdaptiveConfig); !errors.Is(err, ErrUnknownFamily) {
+		t.Fatalf("expected: %v, got: %v", ErrUnknownFamily, err)
+	}
+	if _, err := c.New(Options{Family: "products", Adaptive: true}, 1, DefaultAdaptiveConfig); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected: %v, got: %v", ErrInvalidOptions, err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template Template
+	}{
+		{"no name", Template{Difficulty: "easy", Shapes: []string{"1+2"}}},
+		{"difficulty", Template{Name: "x", Difficulty: "insane", Shapes: []string{"1+2"}}},
+		{"syntax", Template{Name: "x", Difficulty: "easy", Shapes: []string{"{a}+"}, DefaultOperand: &Range{1, 2}}},
+		{"no range", Template{Name: "x", Difficulty: "easy", Shapes: []string{"{a}+1"}}},
+		{"operator", Template{Name: "x", Difficulty: "easy", Shapes: []string{"2*3"}, Operators: []string{"+"}}},
+		{"unknown operator", Template{Name: "x", Difficulty: "easy", Operators: []string{"%"}}},
+		{"no shapes", Template{Name: "x", Difficulty: "easy"}},
+		{"unsatisfiable", Template{Name: "x", Difficulty: "easy", Shapes: []string{"1+2"}, Answer: &Range{4, 5}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile(tt.template); !errors.Is(err, ErrInvalidTemplate) {
+				t.Fatalf("expected: %v, got: %v", ErrInvalidTemplate, err)
+			}
+		})
+	}
+}
+
+func TestNonNegative(t *testing.T) {
+	tests := []struct {
+		expr math.ExpressionInt
+		want bool
+	}{
+		{math.Sum{math.Num(5), math.Num(-3), math.Num(1)}, true},
+		{math.Sum{math.Num(2), math.Num(-3), math.Num(5)}, false},
+		{math.Sum{math.Num(-1), math.Num(3)}, false},
+		{math.Product{math.Num(2), math.Difference{Minuend: math.Num(1), Subtrahend: math.Num(-2)}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := nonNegative(tt.expr); got != tt.want {
+			t.Fatalf("%s: expected: %v, got: %v", tt.expr.Marshal(), tt.want, got)
+		}
+	}
+}
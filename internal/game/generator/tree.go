@@ -0,0 +1,88 @@
+package generator
+
+import (
+	"github.com/pelageech/matharena/internal/game/math"
+)
+
+type operator int
+
+const (
+	opAdd operator = iota
+	opSub
+	opMul
+	opDiv
+	opPow
+	opFact
+)
+
+// tree builds random expression trees.
+type tree struct {
+	src source
+
+	// ops are the operators to choose from, an operator may be listed
+	// several times to make it more frequent.
+	ops []operator
+
+	// leafMin and leafMax bound the numbers in additions and subtractions.
+	// Operands of the other operators are kept small so that a player
+	// can compute the answer in mind.
+	leafMin, leafMax int
+}
+
+// intN returns a random number in [lo, hi].
+func (t tree) intN(lo, hi int) int {
+	return lo + t.src.IntN(hi-lo+1)
+}
+
+func (t tree) leaf() math.ExpressionInt {
+	return math.Num(t.intN(t.leafMin, t.leafMax))
+}
+
+// build returns a random tree with at most depth levels of operators.
+func (t tree) build(depth int) math.ExpressionInt {
+	if depth == 0 || len(t.ops) == 0 {
+		return t.leaf()
+	}
+
+	switch t.ops[t.src.IntN(len(t.ops))] {
+	case opAdd:
+		return math.Sum{t.build(depth - 1), t.build(depth - 1)}
+	case opSub:
+		return math.Difference{Minuend: t.build(depth - 1), Subtrahend: t.build(depth - 1)}
+	case opMul:
+		return math.Product{t.factor(depth - 1), math.Num(t.intN(2, 12))}
+	case opDiv:
+		return t.quotient(depth - 1)
+	case opPow:
+		return math.Power{Base: math.Num(t.intN(2, 9)), Exp: math.Num(t.intN(2, 3))}
+	case opFact:
+		return math.Fact{Fact: math.Num(t.intN(3, 6))}
+	}
+	return t.leaf()
+}
+
+// factor returns a small subtree to be multiplied.
+func (t tree) factor(depth int) math.ExpressionInt {
+	if depth == 0 || t.src.IntN(2) == 0 {
+		return math.Num(t.intN(2, 12))
+	}
+	return t.build(depth - 1)
+}
+
+// quotient returns an exact division. The dividend is a random subtree
+// if it has a small divisor, otherwise a multiple of a small number.
+func (t tree) quotient(depth int) math.ExpressionInt {
+	if depth > 0 {
+		dividend := t.build(depth - 1)
+		if v, err := dividend.Evaluate(); err == nil && v != 0 {
+			for _, d := range t.src.Perm(11) {
+				if d += 2; v%d == 0 {
+					return math.Quotient{Dividend: dividend, Divisor: math.Num(d)}
+				}
+			}
+		}
+	}
+
+	d := t.intN(2, 12)
+	return math.Quotient{Dividend: math.Num(d * t.intN(2, 12)), Divisor: math.Num(d)}
+}
@@ -0,0 +1,33 @@
+package math
+
+import "fmt"
+
+const (
+	_maxInt = int(^uint(0) >> 1)
+	_minInt = -_maxInt - 1
+)
+
+func add(a, b int) (int, error) {
+	if b > 0 && a > _maxInt-b || b < 0 && a < _minInt-b {
+		return 0, fmt.Errorf("%d+%d: %w", a, b, ErrOverflow)
+	}
+	return a + b, nil
+}
+
+func sub(a, b int) (int, error) {
+	if b < 0 && a > _maxInt+b || b > 0 && a < _minInt+b {
+		return 0, fmt.Errorf("%d-%d: %w", a, b, ErrOverflow)
+	}
+	return a - b, nil
+}
+
+func mul(a, b int) (int, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	c := a * b
+	if c/b != a || a == -1 && b == _minInt || b == -1 && a == _minInt {
+		return 0, fmt.Errorf("%d*%d: %w", a, b, ErrOverflow)
+	}
+	return c, nil
+}
@@ -2,20 +2,101 @@ package math
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"strconv"
 )
 
+var (
+	ErrOverflow          = errors.New("int overflow")
+	ErrNegativeFactorial = errors.New("factorial of a negative number")
+	ErrDivisionByZero    = errors.New("division by zero")
+	ErrInexactDivision   = errors.New("inexact division")
+	ErrNegativeExponent  = errors.New("negative exponent")
+)
+
+// ExpressionInt is an integer expression tree.
+//
+// Evaluate computes the value of the expression and reports an error
+// if the expression is invalid or overflows int. Calculate is the same
+// as Evaluate but panics on error, so it must only be used for trees
+// that have been checked with Evaluate.
 type ExpressionInt interface {
 	Calculate() int
+	Evaluate() (int, error)
 	Marshal() []byte
 }
 
+func mustEvaluate(e ExpressionInt) int {
+	i, err := e.Evaluate()
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+// Operator precedences used by Marshal to decide where parentheses are needed.
+const (
+	_precSum = iota + 1
+	_precProduct
+	_precPower
+	_precFact
+	_precNum
+)
+
+func precedence(e ExpressionInt) int {
+	switch e.(type) {
+	case Sum, Difference:
+		return _precSum
+	case Product, Quotient:
+		return _precProduct
+	case Power:
+		return _precPower
+	case Fact:
+		return _precFact
+	}
+	return _precNum
+}
+
+// startsWithMinus reports whether the marshalled form of e begins with a minus sign.
+func startsWithMinus(e ExpressionInt) bool {
+	switch v := e.(type) {
+	case Num:
+		return v < 0
+	case Sum:
+		return len(v) > 0 && startsWithMinus(v[0])
+	case Product:
+		return len(v) > 0 && startsWithMinus(v[0])
+	case Difference:
+		return startsWithMinus(v.Minuend)
+	case Quotient:
+		return startsWithMinus(v.Dividend)
+	}
+	return false
+}
+
+// writeOperand writes e into b and wraps it into parentheses if its precedence is lower than prec.
+// An operand which is not leading in the expression is also wrapped if it starts with a minus sign.
+func writeOperand(b *bytes.Buffer, e ExpressionInt, prec int, leading bool) {
+	if precedence(e) < prec || !leading && startsWithMinus(e) {
+		b.WriteByte('(')
+		b.Write(e.Marshal())
+		b.WriteByte(')')
+		return
+	}
+	b.Write(e.Marshal())
+}
+
 type Num int
 
 func (n Num) Calculate() int {
 	return int(n)
 }
 
+func (n Num) Evaluate() (int, error) {
+	return int(n), nil
+}
+
 func (n Num) Marshal() []byte {
 	return []byte(strconv.Itoa(int(n)))
 }
@@ -33,66 +114,257 @@ func init() {
 }
 
 func (n Fact) Calculate() int {
-	c := n.Fact.Calculate()
+	return mustEvaluate(n)
+}
+
+func (n Fact) Evaluate() (int, error) {
+	c, err := n.Fact.Evaluate()
+	if err != nil {
+		return 0, err
+	}
 	if c < 0 {
-		panic("argument is below zero")
+		return 0, fmt.Errorf("%d!: %w", c, ErrNegativeFactorial)
 	}
-	if c > 20 {
-		panic("int overflow")
+	if c >= len(_facts) {
+		return 0, fmt.Errorf("%d!: %w", c, ErrOverflow)
 	}
 
-	return _facts[c]
+	return _facts[c], nil
 }
 
 func (n Fact) Marshal() []byte {
-	return append(n.Fact.Marshal(), '!')
+	b := &bytes.Buffer{}
+	writeOperand(b, n.Fact, _precNum, false)
+	b.WriteByte('!')
+	return b.Bytes()
 }
 
 type Sum []ExpressionInt
 
 func (s Sum) Calculate() int {
+	return mustEvaluate(s)
+}
+
+func (s Sum) Evaluate() (int, error) {
 	i := 0
 	for _, x := range s {
-		i += x.Calculate()
+		c, err := x.Evaluate()
+		if err != nil {
+			return 0, err
+		}
+		if i, err = add(i, c); err != nil {
+			return 0, err
+		}
 	}
-	return i
+	return i, nil
+}
+
+// flatten returns the terms of s with all nested sums expanded in place.
+func (s Sum) flatten() Sum {
+	flat := make(Sum, 0, len(s))
+	for _, x := range s {
+		if v, ok := x.(Sum); ok {
+			flat = append(flat, v.flatten()...)
+			continue
+		}
+		flat = append(flat, x)
+	}
+	return flat
 }
 
 func (s Sum) Marshal() []byte {
+	s = s.flatten()
 	if len(s) == 0 {
 		return nil
 	}
 
 	b := &bytes.Buffer{}
-
-	switch v := s[0].(type) {
-	case Sum, Num:
-		b.Write(v.Marshal())
-	default:
-		b.WriteByte('(')
-		b.Write(v.Marshal())
-		b.WriteByte(')')
-	}
+	writeOperand(b, s[0], _precSum, true)
 
 	for _, x := range s[1:] {
-		switch v := x.(type) {
-		case Sum:
-			b.WriteByte('+')
-			b.Write(v.Marshal())
-		case Num:
-			if v.Calculate() < 0 {
-				b.Write(v.Marshal())
-			} else {
-				b.WriteByte('+')
-				b.Write(v.Marshal())
-			}
-		default:
-			b.WriteByte('+')
-			b.WriteByte('(')
+		if v, ok := x.(Num); ok && v < 0 {
 			b.Write(v.Marshal())
-			b.WriteByte(')')
+			continue
 		}
+		b.WriteByte('+')
+		writeOperand(b, x, _precProduct, false)
+	}
+
+	return b.Bytes()
+}
+
+// Difference is a subtraction of Subtrahend from Minuend.
+type Difference struct {
+	Minuend    ExpressionInt `json:"minuend"`
+	Subtrahend ExpressionInt `json:"subtrahend"`
+}
+
+func (d Difference) Calculate() int {
+	return mustEvaluate(d)
+}
+
+func (d Difference) Evaluate() (int, error) {
+	a, err := d.Minuend.Evaluate()
+	if err != nil {
+		return 0, err
+	}
+	b, err := d.Subtrahend.Evaluate()
+	if err != nil {
+		return 0, err
 	}
+	return sub(a, b)
+}
+
+func (d Difference) Marshal() []byte {
+	b := &bytes.Buffer{}
+	writeOperand(b, d.Minuend, _precSum, true)
+	b.WriteByte('-')
+	writeOperand(b, d.Subtrahend, _precProduct, false)
+	return b.Bytes()
+}
 
+// Product is a multiplication of all its factors.
+type Product []ExpressionInt
+
+func (p Product) Calculate() int {
+	return mustEvaluate(p)
+}
+
+func (p Product) Evaluate() (int, error) {
+	i := 1
+	for _, x := range p {
+		c, err := x.Evaluate()
+		if err != nil {
+			return 0, err
+		}
+		if i, err = mul(i, c); err != nil {
+			return 0, err
+		}
+	}
+	return i, nil
+}
+
+// flatten returns the factors of p with all nested products expanded in place.
+func (p Product) flatten() Product {
+	flat := make(Product, 0, len(p))
+	for _, x := range p {
+		if v, ok := x.(Product); ok {
+			flat = append(flat, v.flatten()...)
+			continue
+		}
+		flat = append(flat, x)
+	}
+	return flat
+}
+
+func (p Product) Marshal() []byte {
+	p = p.flatten()
+	if len(p) == 0 {
+		return nil
+	}
+
+	b := &bytes.Buffer{}
+	writeOperand(b, p[0], _precProduct, true)
+
+	for _, x := range p[1:] {
+		b.WriteByte('*')
+		writeOperand(b, x, _precPower, false)
+	}
+
+	return b.Bytes()
+}
+
+// Quotient is an exact integer division of Dividend by Divisor.
+// Dividend must be a multiple of Divisor.
+type Quotient struct {
+	Dividend ExpressionInt `json:"dividend"`
+	Divisor  ExpressionInt `json:"divisor"`
+}
+
+func (q Quotient) Calculate() int {
+	return mustEvaluate(q)
+}
+
+func (q Quotient) Evaluate() (int, error) {
+	a, err := q.Dividend.Evaluate()
+	if err != nil {
+		return 0, err
+	}
+	b, err := q.Divisor.Evaluate()
+	if err != nil {
+		return 0, err
+	}
+	if b == 0 {
+		return 0, fmt.Errorf("%d/%d: %w", a, b, ErrDivisionByZero)
+	}
+	if b == -1 && a == _minInt {
+		return 0, fmt.Errorf("%d/%d: %w", a, b, ErrOverflow)
+	}
+	if a%b != 0 {
+		return 0, fmt.Errorf("%d/%d: %w", a, b, ErrInexactDivision)
+	}
+
+	return a / b, nil
+}
+
+func (q Quotient) Marshal() []byte {
+	b := &bytes.Buffer{}
+	writeOperand(b, q.Dividend, _precProduct, true)
+	b.WriteByte('/')
+	writeOperand(b, q.Divisor, _precPower, false)
+	return b.Bytes()
+}
+
+// Power raises Base to the non-negative power Exp. Power is right-associative.
+type Power struct {
+	Base ExpressionInt `json:"base"`
+	Exp  ExpressionInt `json:"exp"`
+}
+
+func (p Power) Calculate() int {
+	return mustEvaluate(p)
+}
+
+func (p Power) Evaluate() (int, error) {
+	base, err := p.Base.Evaluate()
+	if err != nil {
+		return 0, err
+	}
+	exp, err := p.Exp.Evaluate()
+	if err != nil {
+		return 0, err
+	}
+	if exp < 0 {
+		return 0, fmt.Errorf("%d^%d: %w", base, exp, ErrNegativeExponent)
+	}
+
+	switch base {
+	case 0, 1:
+		if exp == 0 {
+			return 1, nil
+		}
+		return base, nil
+	case -1:
+		if exp%2 == 0 {
+			return 1, nil
+		}
+		return -1, nil
+	}
+
+	// |base| >= 2, so the loop overflows after at most 63 iterations
+	i := 1
+	for range exp {
+		if i, err = mul(i, base); err != nil {
+			return 0, fmt.Errorf("%d^%d: %w", base, exp, ErrOverflow)
+		}
+	}
+	return i, nil
+}
+
+func (p Power) Marshal() []byte {
+	b := &bytes.Buffer{}
+	writeOperand(b, p.Base, _precFact, false)
+	b.WriteByte('^')
+	writeOperand(b, p.Exp, _precPower, false)
 	return b.Bytes()
 }
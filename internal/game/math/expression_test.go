@@ -1,6 +1,7 @@
 package math
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -25,3 +26,153 @@ func TestExpressionJSON(t *testing.T) {
 		t.Fatalf("expected: `%v`, got: `%v`", "23+10+90-9+1", string(sum.Marshal()))
 	}
 }
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		expr ExpressionInt
+		want string
+		calc int
+	}{
+		{
+			name: "mixed",
+			expr: Difference{
+				Minuend:    Product{Sum{Num(3), Num(4)}, Num(5)},
+				Subtrahend: Power{Base: Num(2), Exp: Num(3)},
+			},
+			want: "(3+4)*5-2^3",
+			calc: 27,
+		},
+		{
+			name: "fact in sum",
+			expr: Sum{Num(12), Fact{Num(5)}},
+			want: "12+5!",
+			calc: 132,
+		},
+		{
+			name: "fact of sum",
+			expr: Fact{Sum{Num(1), Num(2)}},
+			want: "(1+2)!",
+			calc: 6,
+		},
+		{
+			name: "subtrahend difference",
+			expr: Difference{Num(10), Difference{Num(4), Num(3)}},
+			want: "10-(4-3)",
+			calc: 9,
+		},
+		{
+			name: "minuend difference",
+			expr: Difference{Difference{Num(10), Num(4)}, Num(3)},
+			want: "10-4-3",
+			calc: 3,
+		},
+		{
+			name: "divisor product",
+			expr: Quotient{Num(24), Product{Num(2), Num(3)}},
+			want: "24/(2*3)",
+			calc: 4,
+		},
+		{
+			name: "dividend product",
+			expr: Quotient{Product{Num(2), Num(3)}, Num(3)},
+			want: "2*3/3",
+			calc: 2,
+		},
+		{
+			name: "nested products",
+			expr: Product{Num(2), Product{Num(3), Num(4)}, Quotient{Num(8), Num(4)}},
+			want: "2*3*4*(8/4)",
+			calc: 48,
+		},
+		{
+			name: "right associative power",
+			expr: Power{Num(2), Power{Num(3), Num(2)}},
+			want: "2^3^2",
+			calc: 512,
+		},
+		{
+			name: "left power",
+			expr: Power{Power{Num(2), Num(3)}, Num(2)},
+			want: "(2^3)^2",
+			calc: 64,
+		},
+		{
+			name: "negative operands",
+			expr: Product{Num(-2), Power{Num(-3), Num(2)}, Num(-1)},
+			want: "-2*(-3)^2*(-1)",
+			calc: 18,
+		},
+		{
+			name: "negative subtrahend",
+			expr: Sum{Num(1), Difference{Num(-2), Num(-3)}},
+			want: "1+(-2-(-3))",
+			calc: 2,
+		},
+		{
+			name: "fact and power",
+			expr: Power{Fact{Num(3)}, Fact{Num(2)}},
+			want: "3!^2!",
+			calc: 36,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tt.expr.Marshal()); got != tt.want {
+				t.Fatalf("expected: `%v`, got: `%v`", tt.want, got)
+			}
+			if got := tt.expr.Calculate(); got != tt.calc {
+				t.Fatalf("Calculate fail: got %d, want %d", got, tt.calc)
+			}
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr ExpressionInt
+		err  error
+	}{
+		{"negative factorial", Fact{Num(-1)}, ErrNegativeFactorial},
+		{"factorial overflow", Fact{Num(21)}, ErrOverflow},
+		{"sum overflow", Sum{Num(_maxInt), Num(1)}, ErrOverflow},
+		{"difference overflow", Difference{Num(_minInt), Num(1)}, ErrOverflow},
+		{"product overflow", Product{Num(1 << 32), Num(1 << 32)}, ErrOverflow},
+		{"power overflow", Power{Num(2), Num(64)}, ErrOverflow},
+		{"huge power", Power{Num(3), Num(_maxInt)}, ErrOverflow},
+		{"negative exponent", Power{Num(2), Num(-1)}, ErrNegativeExponent},
+		{"division by zero", Quotient{Num(1), Sum{Num(2), Num(-2)}}, ErrDivisionByZero},
+		{"inexact division", Quotient{Num(7), Num(2)}, ErrInexactDivision},
+		{"nested", Sum{Num(1), Product{Num(2), Fact{Num(-3)}}}, ErrNegativeFactorial},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.expr.Evaluate()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected: %v, got: %v", tt.err, err)
+			}
+		})
+	}
+
+	if got, err := (Power{Num(-1), Num(_maxInt)}).Evaluate(); err != nil || got != -1 {
+		t.Fatalf("expected: -1, got: %d, %v", got, err)
+	}
+}
+
+func TestOperands(t *testing.T) {
+	e := Sum{Num(1), Product{Num(2), Fact{Num(3)}}, Power{Num(2), Num(2)}}
+
+	operands := Operands(e)
+	if len(operands) != 3 {
+		t.Fatalf("expected: 3 operands, got: %d", len(operands))
+	}
+	if got := Operands(operands[1]); len(got) != 2 || got[1].Calculate() != 6 {
+		t.Fatalf("unexpected operands: %#v", got)
+	}
+	if got := Operands(Num(1)); got != nil {
+		t.Fatalf("expected: nil, got: %#v", got)
+	}
+}
@@ -0,0 +1,168 @@
+package math
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Operation names used in the JSON representation of an expression.
+const (
+	OpNum        = "num"
+	OpSum        = "sum"
+	OpDifference = "difference"
+	OpProduct    = "product"
+	OpQuotient   = "quotient"
+	OpPower      = "power"
+	OpFact       = "fact"
+)
+
+var ErrInvalidNode = errors.New("invalid expression node")
+
+// Node is a tagged JSON representation of an expression tree, e.g.
+//
+//	{"op":"sum","args":[{"op":"num","value":2},{"op":"fact","args":[{"op":"num","value":3}]}]}
+//
+// Value is set for numbers only. Difference, Quotient and Power have exactly two arguments
+// in the order they are written, Fact has one.
+type Node struct {
+	Op    string `json:"op"`
+	Value *int   `json:"value,omitempty"`
+	Args  []Node `json:"args,omitempty"`
+}
+
+// NewNode converts an expression tree into its JSON representation.
+func NewNode(e ExpressionInt) (Node, error) {
+	switch v := e.(type) {
+	case Num:
+		i := int(v)
+		return Node{Op: OpNum, Value: &i}, nil
+	case Sum:
+		return newNode(OpSum, v...)
+	case Product:
+		return newNode(OpProduct, v...)
+	case Difference:
+		return newNode(OpDifference, v.Minuend, v.Subtrahend)
+	case Quotient:
+		return newNode(OpQuotient, v.Dividend, v.Divisor)
+	case Power:
+		return newNode(OpPower, v.Base, v.Exp)
+	case Fact:
+		return newNode(OpFact, v.Fact)
+	}
+	return Node{}, fmt.Errorf("%T: %w", e, ErrInvalidNode)
+}
+
+func newNode(op string, args ...ExpressionInt) (Node, error) {
+	n := Node{Op: op, Args: make([]Node, 0, len(args))}
+	for _, arg := range args {
+		a, err := NewNode(arg)
+		if err != nil {
+			return Node{}, err
+		}
+		n.Args = append(n.Args, a)
+	}
+	return n, nil
+}
+
+// Expression converts the node back into an expression tree.
+func (n Node) Expression() (ExpressionInt, error) {
+	if n.Op == OpNum {
+		if n.Value == nil || len(n.Args) != 0 {
+			return nil, fmt.Errorf("%s: value is required and args are not allowed: %w", n.Op, ErrInvalidNode)
+		}
+		return Num(*n.Value), nil
+	}
+	if n.Value != nil {
+		return nil, fmt.Errorf("%s: value is not allowed: %w", n.Op, ErrInvalidNode)
+	}
+
+	args := make([]ExpressionInt, 0, len(n.Args))
+	for _, a := range n.Args {
+		e, err := a.Expression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+	}
+
+	arity := func(want int) error {
+		if len(args) != want {
+			return fmt.Errorf("%s: expected %d args, got %d: %w", n.Op, want, len(args), ErrInvalidNode)
+		}
+		return nil
+	}
+
+	switch n.Op {
+	case OpSum:
+		return Sum(args), nil
+	case OpProduct:
+		return Product(args), nil
+	case OpDifference:
+		if err := arity(2); err != nil {
+			return nil, err
+		}
+		return Difference{Minuend: args[0], Subtrahend: args[1]}, nil
+	case OpQuotient:
+		if err := arity(2); err != nil {
+			return nil, err
+		}
+		return Quotient{Dividend: args[0], Divisor: args[1]}, nil
+	case OpPower:
+		if err := arity(2); err != nil {
+			return nil, err
+		}
+		return Power{Base: args[0], Exp: args[1]}, nil
+	case OpFact:
+		if err := arity(1); err != nil {
+			return nil, err
+		}
+		return Fact{Fact: args[0]}, nil
+	}
+	return nil, fmt.Errorf("unknown op %q: %w", n.Op, ErrInvalidNode)
+}
+
+// MarshalJSON encodes an expression tree as tagged JSON.
+func MarshalJSON(e ExpressionInt) ([]byte, error) {
+	n, err := NewNode(e)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(n)
+}
+
+// UnmarshalJSON decodes an expression tree encoded by MarshalJSON.
+func UnmarshalJSON(b []byte) (ExpressionInt, error) {
+	var n Node
+	if err := json.Unmarshal(b, &n); err != nil {
+		return nil, fmt.Errorf("unmarshal expression: %w", err)
+	}
+	return n.Expression()
+}
+
+// AST wraps an expression so that it can be used as a field of structs
+// encoded with encoding/json. A nil expression is encoded as null.
+type AST struct {
+	ExpressionInt
+}
+
+func (a AST) MarshalJSON() ([]byte, error) {
+	if a.ExpressionInt == nil {
+		return []byte("null"), nil
+	}
+	return MarshalJSON(a.ExpressionInt)
+}
+
+func (a *AST) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		a.ExpressionInt = nil
+		return nil
+	}
+
+	e, err := UnmarshalJSON(b)
+	if err != nil {
+		return err
+	}
+	a.ExpressionInt = e
+	return nil
+}
@@ -0,0 +1,87 @@
+package math
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	e := Difference{
+		Minuend:    Sum{Num(0), Fact{Num(3)}},
+		Subtrahend: Power{Base: Num(-2), Exp: Num(2)},
+	}
+
+	b, err := MarshalJSON(e)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `{"op":"difference","args":[` +
+		`{"op":"sum","args":[{"op":"num","value":0},{"op":"fact","args":[{"op":"num","value":3}]}]},` +
+		`{"op":"power","args":[{"op":"num","value":-2},{"op":"num","value":2}]}]}`
+	if string(b) != want {
+		t.Fatalf("expected: `%v`, got: `%v`", want, string(b))
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+
+	for range 1000 {
+		e := randomExpression(r, 4)
+
+		b, err := MarshalJSON(e)
+		if err != nil {
+			t.Fatalf("marshal %s: %v", e.Marshal(), err)
+		}
+
+		got, err := UnmarshalJSON(b)
+		if err != nil {
+			t.Fatalf("unmarshal %s: %v", b, err)
+		}
+		if !reflect.DeepEqual(got, e) {
+			t.Fatalf("expected: %#v, got: %#v", e, got)
+		}
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	tests := []string{
+		`{"op":"num"}`,
+		`{"op":"num","value":1,"args":[{"op":"num","value":1}]}`,
+		`{"op":"sum","value":1}`,
+		`{"op":"power","args":[{"op":"num","value":1}]}`,
+		`{"op":"fact"}`,
+		`{"op":"modulo","args":[]}`,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt, func(t *testing.T) {
+			if _, err := UnmarshalJSON([]byte(tt)); !errors.Is(err, ErrInvalidNode) {
+				t.Fatalf("expected: %v, got: %v", ErrInvalidNode, err)
+			}
+		})
+	}
+}
+
+func TestAST(t *testing.T) {
+	type puzzle struct {
+		Expression AST `json:"expression"`
+	}
+
+	b, err := json.Marshal(puzzle{Expression: AST{Product{Num(2), Num(3)}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got puzzle
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Expression.Calculate() != 6 {
+		t.Fatalf("Calculate fail: got %d, want %d", got.Expression.Calculate(), 6)
+	}
+}
@@ -0,0 +1,54 @@
+package math
+
+// Normalize returns the canonical form of e, which is the tree Parse builds
+// from e.Marshal(). Nested sums and products are flattened, single-element
+// sums and products are replaced with the element and a subtraction of a
+// positive number becomes a negative term of a Sum; a zero term would be
+// marshaled with a plus sign.
+func Normalize(e ExpressionInt) ExpressionInt {
+	switch v := e.(type) {
+	case Sum:
+		s := Sum{}
+		for _, x := range v {
+			s = appendNormalized(s, Normalize(x))
+		}
+		if len(s) == 1 {
+			return s[0]
+		}
+		return s
+	case Product:
+		p := Product{}
+		for _, x := range v {
+			x = Normalize(x)
+			if inner, ok := x.(Product); ok {
+				p = append(p, inner...)
+				continue
+			}
+			p = append(p, x)
+		}
+		if len(p) == 1 {
+			return p[0]
+		}
+		return p
+	case Difference:
+		minuend, subtrahend := Normalize(v.Minuend), Normalize(v.Subtrahend)
+		if n, ok := subtrahend.(Num); ok && n > 0 {
+			return appendNormalized(appendNormalized(Sum{}, minuend), -n)
+		}
+		return Difference{Minuend: minuend, Subtrahend: subtrahend}
+	case Quotient:
+		return Quotient{Dividend: Normalize(v.Dividend), Divisor: Normalize(v.Divisor)}
+	case Power:
+		return Power{Base: Normalize(v.Base), Exp: Normalize(v.Exp)}
+	case Fact:
+		return Fact{Fact: Normalize(v.Fact)}
+	}
+	return e
+}
+
+func appendNormalized(s Sum, x ExpressionInt) Sum {
+	if inner, ok := x.(Sum); ok {
+		return append(s, inner...)
+	}
+	return append(s, x)
+}
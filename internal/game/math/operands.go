@@ -0,0 +1,20 @@
+package math
+
+// Operands returns the direct subexpressions of e in the order they are written.
+func Operands(e ExpressionInt) []ExpressionInt {
+	switch v := e.(type) {
+	case Sum:
+		return v
+	case Product:
+		return v
+	case Difference:
+		return []ExpressionInt{v.Minuend, v.Subtrahend}
+	case Quotient:
+		return []ExpressionInt{v.Dividend, v.Divisor}
+	case Power:
+		return []ExpressionInt{v.Base, v.Exp}
+	case Fact:
+		return []ExpressionInt{v.Fact}
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package math
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SyntaxError describes a malformed expression passed to Parse.
+type SyntaxError struct {
+	// Pos is the byte offset of the offending token.
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+type tokenKind int
+
+const (
+	_tokenEOF tokenKind = iota
+	_tokenNum
+	_tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	pos  int
+	text string
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			tokens = append(tokens, token{kind: _tokenNum, pos: i, text: s[i:j]})
+			i = j
+		case c == '+' || c == '-' || c == '*' || c == '/' || c == '^' || c == '!' || c == '(' || c == ')':
+			tokens = append(tokens, token{kind: _tokenOp, pos: i, text: s[i : i+1]})
+			i++
+		default:
+			return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(tokens, token{kind: _tokenEOF, pos: len(s)}), nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+// Parse reads an infix expression with + - * / ^ ! and parentheses.
+//
+// Parse always returns a normalized tree, so Parse(e.Marshal()) is structurally
+// equal to Normalize(e) for every expression e.
+func Parse(s string) (ExpressionInt, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != _tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != _tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != _tokenOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == _tokenEOF {
+		return &SyntaxError{Pos: t.pos, Msg: "unexpected end of expression"}
+	}
+	return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+}
+
+// parseSum parses a chain of terms joined with + and -.
+// Subtraction of a positive number literal, bare or parenthesised, is stored
+// as a negative term of a Sum, the same way Normalize and Sum.Marshal treat it.
+func (p *parser) parseSum() (ExpressionInt, error) {
+	acc, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("+", "-") {
+		op := p.next()
+		x, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+
+		n, isNum := x.(Num)
+		switch {
+		case op.text == "+":
+			acc = appendSum(acc, x)
+		case isNum && n > 0:
+			acc = appendSum(acc, -n)
+		default:
+			acc = Difference{Minuend: acc, Subtrahend: x}
+		}
+	}
+
+	return acc, nil
+}
+
+// parseProduct parses a chain of powers joined with * and /.
+func (p *parser) parseProduct() (ExpressionInt, error) {
+	acc, err := p.parsePower()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("*", "/") {
+		op := p.next()
+		x, err := p.parsePower()
+		if err != nil {
+			return nil, err
+		}
+
+		if op.text == "*" {
+			acc = appendProduct(acc, x)
+		} else {
+			acc = Quotient{Dividend: acc, Divisor: x}
+		}
+	}
+
+	return acc, nil
+}
+
+// parsePower parses a right-associative chain of factorials joined with ^.
+func (p *parser) parsePower() (ExpressionInt, error) {
+	base, err := p.parseFact()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isOp("^") {
+		return base, nil
+	}
+	p.next()
+
+	exp, err := p.parsePower()
+	if err != nil {
+		return nil, err
+	}
+	return Power{Base: base, Exp: exp}, nil
+}
+
+func (p *parser) parseFact() (ExpressionInt, error) {
+	e, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("!") {
+		p.next()
+		e = Fact{Fact: e}
+	}
+	return e, nil
+}
+
+func (p *parser) parsePrimary() (ExpressionInt, error) {
+	t := p.next()
+	switch {
+	case t.kind == _tokenNum:
+		return p.number(t, "")
+	case t.kind == _tokenOp && t.text == "-":
+		n := p.next()
+		if n.kind != _tokenNum {
+			return nil, &SyntaxError{Pos: t.pos, Msg: "unary minus is allowed only before a number"}
+		}
+		if p.isOp("^", "!") {
+			return nil, &SyntaxError{Pos: t.pos, Msg: "ambiguous unary minus, use parentheses"}
+		}
+		return p.number(n, "-")
+	case t.kind == _tokenOp && t.text == "(":
+		e, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, &SyntaxError{Pos: p.peek().pos, Msg: fmt.Sprintf("missing ')' for '(' at position %d", t.pos)}
+		}
+		p.next()
+		return e, nil
+	}
+	return nil, p.unexpected(t)
+}
+
+func (p *parser) number(t token, sign string) (ExpressionInt, error) {
+	n, err := strconv.Atoi(sign + t.text)
+	if err != nil {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("number %s%s is out of range", sign, t.text)}
+	}
+	return Num(n), nil
+}
+
+func appendSum(acc, x ExpressionInt) Sum {
+	s, ok := acc.(Sum)
+	if !ok {
+		s = Sum{acc}
+	}
+	if v, ok := x.(Sum); ok {
+		return append(s, v...)
+	}
+	return append(s, x)
+}
+
+func appendProduct(acc, x ExpressionInt) Product {
+	s, ok := acc.(Product)
+	if !ok {
+		s = Product{acc}
+	}
+	if v, ok := x.(Product); ok {
+		return append(s, v...)
+	}
+	return append(s, x)
+}
@@ -0,0 +1,135 @@
+package math
+
+import (
+	"errors"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ExpressionInt
+	}{
+		{"42", Num(42)},
+		{" -7 ", Num(-7)},
+		{"23+10+90-9+1", Sum{Num(23), Num(10), Num(90), Num(-9), Num(1)}},
+		{"(3+4)*5-2^3", Difference{
+			Minuend:    Product{Sum{Num(3), Num(4)}, Num(5)},
+			Subtrahend: Power{Base: Num(2), Exp: Num(3)},
+		}},
+		{"2^3^2", Power{Num(2), Power{Num(3), Num(2)}}},
+		{"12+5!", Sum{Num(12), Fact{Num(5)}}},
+		{"3!!", Fact{Fact{Num(3)}}},
+		{"24/(2*3)", Quotient{Num(24), Product{Num(2), Num(3)}}},
+		{"2*(3*4)", Product{Num(2), Num(3), Num(4)}},
+		{"10-(4-3)", Difference{Num(10), Sum{Num(4), Num(-3)}}},
+		{"1-(-2)", Difference{Num(1), Num(-2)}},
+		{"2*-3", Product{Num(2), Num(-3)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected: %#v, got: %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		pos int
+	}{
+		{"", 0},
+		{"1+", 2},
+		{"(1+2", 4},
+		{"1+2)", 3},
+		{"2*x", 2},
+		{"-2^2", 0},
+		{"-(1)", 0},
+		{"99999999999999999999", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, err := Parse(tt.in)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected SyntaxError, got: %v", err)
+			}
+			if syntaxErr.Pos != tt.pos {
+				t.Fatalf("expected error at %d, got: %v", tt.pos, err)
+			}
+		})
+	}
+}
+
+func randomExpression(r *rand.Rand, depth int) ExpressionInt {
+	if depth == 0 || r.IntN(4) == 0 {
+		return Num(r.IntN(41) - 20)
+	}
+
+	switch r.IntN(6) {
+	case 0:
+		return Sum{randomExpression(r, depth-1), randomExpression(r, depth-1), randomExpression(r, depth-1)}
+	case 1:
+		return Product{randomExpression(r, depth-1), randomExpression(r, depth-1)}
+	case 2:
+		return Difference{randomExpression(r, depth-1), randomExpression(r, depth-1)}
+	case 3:
+		return Quotient{randomExpression(r, depth-1), randomExpression(r, depth-1)}
+	case 4:
+		return Power{randomExpression(r, depth-1), randomExpression(r, depth-1)}
+	default:
+		return Fact{randomExpression(r, depth-1)}
+	}
+}
+
+func TestParseMarshalRoundTrip(t *testing.T) {
+	// parenthesised literals are normalized like the bare ones
+	for _, s := range []string{"1-(2)", "1-((2))", "1-(-2)", "(1)-(0)", "10-0"} {
+		got, err := Parse(s)
+		if err != nil {
+			t.Fatalf("parse %q: %v", s, err)
+		}
+		if again, err := Parse(string(got.Marshal())); err != nil || !reflect.DeepEqual(again, got) {
+			t.Fatalf("parse %q: %#v does not round-trip: %#v, %v", s, got, again, err)
+		}
+	}
+
+	// the players see the text they are given
+	for _, s := range []string{"10-0", "10-2", "10+0"} {
+		got, err := Parse(s)
+		if err != nil {
+			t.Fatalf("parse %q: %v", s, err)
+		}
+		if m := string(got.Marshal()); m != s {
+			t.Fatalf("parse %q: marshaled as %q", s, m)
+		}
+	}
+
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for range 10000 {
+		e := randomExpression(r, 4)
+		s := string(e.Marshal())
+
+		got, err := Parse(s)
+		if err != nil {
+			t.Fatalf("parse %q: %v", s, err)
+		}
+		if want := Normalize(e); !reflect.DeepEqual(got, want) {
+			t.Fatalf("parse %q: expected: %#v, got: %#v", s, want, got)
+		}
+		if again, err := Parse(string(got.Marshal())); err != nil || !reflect.DeepEqual(again, got) {
+			t.Fatalf("parse %q: normalized tree does not round-trip: %#v, %v", s, again, err)
+		}
+	}
+}
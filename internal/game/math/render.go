@@ -0,0 +1,231 @@
+package math
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Output formats supported by NewRenderer.
+const (
+	FormatPlain  = "plain"
+	FormatLaTeX  = "latex"
+	FormatMathML = "mathml"
+)
+
+var ErrUnknownFormat = errors.New("unknown expression format")
+
+// Renderer turns an expression tree into its textual representation.
+type Renderer interface {
+	Render(e ExpressionInt) []byte
+}
+
+// NewRenderer returns a renderer for one of the Format* constants.
+func NewRenderer(format string) (Renderer, error) {
+	switch format {
+	case FormatPlain:
+		return PlainRenderer{}, nil
+	case FormatLaTeX:
+		return LaTeXRenderer{}, nil
+	case FormatMathML:
+		return MathMLRenderer{}, nil
+	}
+	return nil, fmt.Errorf("%q: %w", format, ErrUnknownFormat)
+}
+
+// PlainRenderer renders expressions the same way ExpressionInt.Marshal does.
+type PlainRenderer struct{}
+
+func (PlainRenderer) Render(e ExpressionInt) []byte {
+	return e.Marshal()
+}
+
+// LaTeXRenderer renders expressions as LaTeX math, e.g. \frac{12}{3}+5!.
+type LaTeXRenderer struct{}
+
+func (LaTeXRenderer) Render(e ExpressionInt) []byte {
+	b := &bytes.Buffer{}
+	typeset(b, e, latex{})
+	return b.Bytes()
+}
+
+// MathMLRenderer renders expressions as Presentation MathML.
+type MathMLRenderer struct{}
+
+func (MathMLRenderer) Render(e ExpressionInt) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"><mrow>`)
+	typeset(b, e, mathML{})
+	b.WriteString(`</mrow></math>`)
+	return b.Bytes()
+}
+
+// typesetter writes the pieces of a typeset expression. The layout of the
+// tree and the placement of parentheses is decided by typeset.
+type typesetter interface {
+	number(b *bytes.Buffer, n Num)
+	operator(b *bytes.Buffer, op byte)
+	parens(b *bytes.Buffer, inner func())
+	fraction(b *bytes.Buffer, dividend, divisor func())
+	power(b *bytes.Buffer, base, exp func())
+}
+
+// typesetPrecedence is like precedence, but a quotient is typeset as a fraction
+// and needs parentheses only as a base of a power or an argument of a factorial.
+func typesetPrecedence(e ExpressionInt) int {
+	if _, ok := e.(Quotient); ok {
+		return _precPower
+	}
+	return precedence(e)
+}
+
+func typesetStartsWithMinus(e ExpressionInt) bool {
+	switch v := e.(type) {
+	case Quotient:
+		return false
+	case Sum:
+		return len(v) > 0 && typesetStartsWithMinus(v[0])
+	case Product:
+		return len(v) > 0 && typesetStartsWithMinus(v[0])
+	case Difference:
+		return typesetStartsWithMinus(v.Minuend)
+	}
+	return startsWithMinus(e)
+}
+
+func typesetOperand(b *bytes.Buffer, e ExpressionInt, t typesetter, prec int, leading bool) {
+	if typesetPrecedence(e) < prec || !leading && typesetStartsWithMinus(e) {
+		t.parens(b, func() { typeset(b, e, t) })
+		return
+	}
+	typeset(b, e, t)
+}
+
+func typeset(b *bytes.Buffer, e ExpressionInt, t typesetter) {
+	switch v := e.(type) {
+	case Num:
+		t.number(b, v)
+	case Sum:
+		v = v.flatten()
+		if len(v) == 0 {
+			return
+		}
+		typesetOperand(b, v[0], t, _precSum, true)
+		for _, x := range v[1:] {
+			if n, ok := x.(Num); ok && n < 0 {
+				t.operator(b, '-')
+				t.number(b, -n)
+				continue
+			}
+			t.operator(b, '+')
+			typesetOperand(b, x, t, _precProduct, false)
+		}
+	case Difference:
+		typesetOperand(b, v.Minuend, t, _precSum, true)
+		t.operator(b, '-')
+		typesetOperand(b, v.Subtrahend, t, _precProduct, false)
+	case Product:
+		v = v.flatten()
+		if len(v) == 0 {
+			return
+		}
+		typesetOperand(b, v[0], t, _precProduct, true)
+		for _, x := range v[1:] {
+			t.operator(b, '*')
+			typesetOperand(b, x, t, _precPower, false)
+		}
+	case Quotient:
+		t.fraction(b,
+			func() { typeset(b, v.Dividend, t) },
+			func() { typeset(b, v.Divisor, t) },
+		)
+	case Power:
+		t.power(b,
+			func() { typesetOperand(b, v.Base, t, _precFact, false) },
+			func() { typeset(b, v.Exp, t) },
+		)
+	case Fact:
+		typesetOperand(b, v.Fact, t, _precNum, false)
+		t.operator(b, '!')
+	}
+}
+
+type latex struct{}
+
+func (latex) number(b *bytes.Buffer, n Num) {
+	b.WriteString(strconv.Itoa(int(n)))
+}
+
+func (latex) operator(b *bytes.Buffer, op byte) {
+	if op == '*' {
+		b.WriteString(` \cdot `)
+		return
+	}
+	b.WriteByte(op)
+}
+
+func (latex) parens(b *bytes.Buffer, inner func()) {
+	b.WriteString(`\left(`)
+	inner()
+	b.WriteString(`\right)`)
+}
+
+func (latex) fraction(b *bytes.Buffer, dividend, divisor func()) {
+	b.WriteString(`\frac{`)
+	dividend()
+	b.WriteString(`}{`)
+	divisor()
+	b.WriteString(`}`)
+}
+
+func (latex) power(b *bytes.Buffer, base, exp func()) {
+	base()
+	b.WriteString(`^{`)
+	exp()
+	b.WriteString(`}`)
+}
+
+type mathML struct{}
+
+func (mathML) number(b *bytes.Buffer, n Num) {
+	if n < 0 {
+		b.WriteString(`<mo>-</mo>`)
+		n = -n
+	}
+	b.WriteString(`<mn>`)
+	b.WriteString(strconv.FormatUint(uint64(n), 10))
+	b.WriteString(`</mn>`)
+}
+
+func (mathML) operator(b *bytes.Buffer, op byte) {
+	b.WriteString(`<mo>`)
+	if op == '*' {
+		b.WriteString(`&#x22C5;`)
+	} else {
+		b.WriteByte(op)
+	}
+	b.WriteString(`</mo>`)
+}
+
+func (mathML) parens(b *bytes.Buffer, inner func()) {
+	b.WriteString(`<mrow><mo>(</mo>`)
+	inner()
+	b.WriteString(`<mo>)</mo></mrow>`)
+}
+
+func (mathML) fraction(b *bytes.Buffer, dividend, divisor func()) {
+	b.WriteString(`<mfrac><mrow>`)
+	dividend()
+	b.WriteString(`</mrow><mrow>`)
+	divisor()
+	b.WriteString(`</mrow></mfrac>`)
+}
+
+func (mathML) power(b *bytes.Buffer, base, exp func()) {
+	b.WriteString(`<msup><mrow>`)
+	base()
+	b.WriteString(`</mrow><mrow>`)
+	exp()
+	b.WriteString(`</mrow></msup>`)
+}
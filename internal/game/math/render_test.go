@@ -0,0 +1,65 @@
+package math
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLaTeXRenderer(t *testing.T) {
+	tests := []struct {
+		expr ExpressionInt
+		want string
+	}{
+		{
+			expr: Difference{Product{Sum{Num(3), Num(4)}, Num(5)}, Power{Num(2), Num(3)}},
+			want: `\left(3+4\right) \cdot 5-2^{3}`,
+		},
+		{
+			expr: Sum{Num(12), Quotient{Num(6), Num(-3)}, Num(-1)},
+			want: `12+\frac{6}{-3}-1`,
+		},
+		{
+			expr: Power{Quotient{Num(4), Num(2)}, Sum{Num(1), Num(1)}},
+			want: `\left(\frac{4}{2}\right)^{1+1}`,
+		},
+		{
+			expr: Product{Num(2), Fact{Product{Num(-1), Num(3)}}},
+			want: `2 \cdot \left(-1 \cdot 3\right)!`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.expr.Marshal()), func(t *testing.T) {
+			if got := string(LaTeXRenderer{}.Render(tt.expr)); got != tt.want {
+				t.Fatalf("expected: `%v`, got: `%v`", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMathMLRenderer(t *testing.T) {
+	e := Sum{Power{Num(-2), Num(2)}, Quotient{Num(6), Num(3)}, Num(-1)}
+	want := `<math xmlns="http://www.w3.org/1998/Math/MathML"><mrow>` +
+		`<msup><mrow><mrow><mo>(</mo><mo>-</mo><mn>2</mn><mo>)</mo></mrow></mrow><mrow><mn>2</mn></mrow></msup>` +
+		`<mo>+</mo><mfrac><mrow><mn>6</mn></mrow><mrow><mn>3</mn></mrow></mfrac>` +
+		`<mo>-</mo><mn>1</mn>` +
+		`</mrow></math>`
+
+	if got := string(MathMLRenderer{}.Render(e)); got != want {
+		t.Fatalf("expected: `%v`, got: `%v`", want, got)
+	}
+}
+
+func TestNewRenderer(t *testing.T) {
+	r, err := NewRenderer(FormatPlain)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(r.Render(Sum{Num(1), Num(2)})); got != "1+2" {
+		t.Fatalf("expected: `%v`, got: `%v`", "1+2", got)
+	}
+
+	if _, err := NewRenderer("svg"); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected: %v, got: %v", ErrUnknownFormat, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand/v2"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/pelageech/matharena/internal/game/generator"
@@ -14,6 +15,10 @@ import (
 const (
 	_defaultDeltaOnCorrect   = 5 * time.Second
 	_defaultDeltaOnIncorrect = 5 * time.Second
+
+	// _maxGenerateAttempts limits how many times the session asks the generator
+	// for a new expression if it keeps producing expressions that cannot be evaluated.
+	_maxGenerateAttempts = 100
 )
 
 type SessionID int64
@@ -34,12 +39,25 @@ func newSessionID() SessionID {
 	return SessionID(rand.Int64())
 }
 
+// FinishReason tells why a session was finished.
+type FinishReason string
+
+const (
+	FinishStopped  FinishReason = "stop"
+	FinishTimeout  FinishReason = "timeout"
+	FinishShutdown FinishReason = "server_shutdown"
+)
+
 type Deltas struct {
 	OnCorrect   time.Duration
 	OnIncorrect time.Duration
 }
 
+// Session is a game of a user. It is safe for concurrent use, the answers
+// to the same session are handled one by one.
 type Session struct {
+	mu sync.Mutex
+
 	sessionID         SessionID
 	userID            int
 	currentExpression math.ExpressionInt
@@ -47,6 +65,12 @@ type Session struct {
 	score             int
 	timeLeft          time.Duration
 	generator         generator.Generator
+	seed              uint64
+	options           generator.Options
+	generated         int
+	lastAnswer        *AnswerRecord
+	stopped           bool
+	finished          bool
 
 	startTime            time.Time
 	finishTime           time.Time
@@ -68,7 +92,23 @@ func WithCustomID(id SessionID) Opt {
 	}
 }
 
-func NewSession(userID int, timeStart time.Duration, generator generator.Generator, timeNow time.Time, opts ...Opt) *Session {
+// WithSeed records the seed the session generator was created with,
+// so that the sequence of expressions can be reproduced.
+func WithSeed(seed uint64) Opt {
+	return func(s *Session) {
+		s.seed = seed
+	}
+}
+
+// WithGeneratorOptions records the options the session generator was created with,
+// so that the generator can be created again when the session is restored.
+func WithGeneratorOptions(o generator.Options) Opt {
+	return func(s *Session) {
+		s.options = o
+	}
+}
+
+func NewSession(userID int, timeStart time.Duration, generator generator.Generator, timeNow time.Time, opts ...Opt) (*Session, error) {
 	s := &Session{
 		sessionID: newSessionID(),
 		userID:    userID,
@@ -85,71 +125,176 @@ func NewSession(userID int, timeStart time.Duration, generator generator.Generat
 		opt(s)
 	}
 
-	s.updateExpression(timeNow)
-	return s
+	if err := s.updateExpression(timeNow); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
 var (
 	ErrAnswerIsIncorrect = errors.New("answer is incorrect")
 	ErrTimeIsLeft        = errors.New("time is left")
+	ErrGenerateFailed    = errors.New("unable to generate a valid expression")
 )
 
+// AnswerRecord describes an answer given in time.
+type AnswerRecord struct {
+	Expression    math.ExpressionInt
+	CorrectAnswer int
+	Answer        int
+	IsCorrect     bool
+	Latency       time.Duration
+
+	// TimeLeft is the time left after the answer.
+	TimeLeft time.Duration
+	Time     time.Time
+}
+
 // Answer handles answer from the user. Checks if the session is ended before it.
 func (s *Session) Answer(answer int, timeNow time.Time) error {
-	s.updateTimeOnAnswer(timeNow)
+	_, err := s.Submit(answer, timeNow)
+	return err
+}
+
+// Submit handles the answer like Answer and returns the record of it,
+// the record is nil if the answer is late.
+func (s *Session) Submit(answer int, timeNow time.Time) (*AnswerRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.handleAnswer(answer, timeNow)
+	return s.lastAnswer, err
+}
+
+func (s *Session) handleAnswer(answer int, timeNow time.Time) (err error) {
+	s.lastAnswer = nil
+	// the session stopped concurrently takes no more answers
+	if s.stopped {
+		return ErrTimeIsLeft
+	}
+	latency := s.updateTimeOnAnswer(timeNow)
 	// check if the user is late to answer
 	if s.timeLeft <= 0 {
-		s.Stop(timeNow.Add(s.timeLeft))
+		s.stop(timeNow.Add(s.timeLeft))
 		return ErrTimeIsLeft
 	}
 
-	defer s.updateExpression(timeNow)
+	if o, ok := s.generator.(generator.Observer); ok {
+		o.Observe(answer == s.answer, latency)
+	}
+
+	s.lastAnswer = &AnswerRecord{
+		Expression:    s.currentExpression,
+		CorrectAnswer: s.answer,
+		Answer:        answer,
+		IsCorrect:     answer == s.answer,
+		Latency:       latency,
+		Time:          timeNow,
+	}
+
+	defer func() {
+		s.lastAnswer.TimeLeft = s.timeLeft
+		if genErr := s.updateExpression(timeNow); genErr != nil {
+			err = genErr
+		}
+	}()
 
 	if answer != s.answer {
 		s.timeOnIncorrect()
 		if s.timeLeft <= 0 { // check after incorrect answer
-			s.Stop(timeNow.Add(s.timeLeft))
+			s.stop(timeNow.Add(s.timeLeft))
 			return ErrTimeIsLeft
 		}
 		return ErrAnswerIsIncorrect
 	}
 
 	s.timeOnCorrect()
-	s.UpdateScore()
+	s.score++
 
 	return nil
 }
 
+// Skip replaces the current expression with a new one. Skipping costs
+// the time of an incorrect answer and does not change the score.
+func (s *Session) Skip(timeNow time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastAnswer = nil
+	if s.stopped {
+		return ErrTimeIsLeft
+	}
+	s.updateTimeOnAnswer(timeNow)
+	s.timeOnIncorrect()
+	if s.timeLeft <= 0 {
+		s.stop(timeNow.Add(s.timeLeft))
+		return ErrTimeIsLeft
+	}
+
+	return s.updateExpression(timeNow)
+}
+
 func (s *Session) CheckTime(timeNow time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if timeNow.Before(s.lastUpdateExpression.Add(s.timeLeft)) {
 		return true
 	}
 
-	s.Stop(s.lastUpdateExpression.Add(s.timeLeft))
+	s.stop(s.lastUpdateExpression.Add(s.timeLeft))
 	return false
 }
 
 func (s *Session) Stop(finishTime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop(finishTime)
+}
+
+func (s *Session) stop(finishTime time.Time) {
 	s.finishTime = finishTime
+	s.stopped = true
 }
 
 func (s *Session) UpdateScore() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.score++
 }
 
+// MarkFinished marks the session finished. It returns false if the session
+// has already been marked, so that its result is stored once.
+func (s *Session) MarkFinished() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.finished {
+		return false
+	}
+	s.finished = true
+	return true
+}
+
 func (s *Session) ID() SessionID {
 	return s.sessionID
 }
 
 func (s *Session) TimeLeft() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.timeLeft
 }
 
 func (s *Session) FinishTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.finishTime
 }
 
 func (s *Session) CurrentExpression() math.ExpressionInt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.currentExpression
 }
 
@@ -158,9 +303,30 @@ func (s *Session) UserID() int {
 }
 
 func (s *Session) Score() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.score
 }
 
+// LastAnswer returns the record of the answer handled by the last call of Answer.
+// It returns nil if there were no answers or the last one was late.
+func (s *Session) LastAnswer() *AnswerRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastAnswer
+}
+
+func (s *Session) Seed() uint64 {
+	return s.seed
+}
+
+// Difficulty returns the current difficulty of the session generator.
+func (s *Session) Difficulty() generator.Difficulty {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generator.Difficulty()
+}
+
 func (s *Session) timeOnCorrect() {
 	s.timeLeft += s.deltas.OnCorrect
 }
@@ -169,12 +335,30 @@ func (s *Session) timeOnIncorrect() {
 	s.timeLeft -= s.deltas.OnIncorrect
 }
 
-func (s *Session) updateTimeOnAnswer(timeNow time.Time) {
-	s.timeLeft -= timeNow.Sub(s.lastUpdateExpression)
+// updateTimeOnAnswer subtracts the time spent on the current expression
+// from the time left and returns it.
+func (s *Session) updateTimeOnAnswer(timeNow time.Time) time.Duration {
+	latency := timeNow.Sub(s.lastUpdateExpression)
+	s.timeLeft -= latency
+	return latency
 }
 
-func (s *Session) updateExpression(timeNow time.Time) {
-	s.currentExpression = s.generator.Generate()
-	s.answer = s.currentExpression.Calculate()
-	s.lastUpdateExpression = timeNow
+// updateExpression asks the generator for a new expression and skips
+// the ones that cannot be evaluated, e.g. because of an int overflow.
+func (s *Session) updateExpression(timeNow time.Time) error {
+	for range _maxGenerateAttempts {
+		e := s.generator.Generate()
+		s.generated++
+		answer, err := e.Evaluate()
+		if err != nil {
+			continue
+		}
+
+		s.currentExpression = e
+		s.answer = answer
+		s.lastUpdateExpression = timeNow
+		return nil
+	}
+
+	return fmt.Errorf("%v: %w", s.generator.Difficulty(), ErrGenerateFailed)
 }
@@ -4,49 +4,146 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var ErrSessionExists = errors.New("session exists")
+var (
+	ErrSessionExists   = errors.New("session exists")
+	ErrSessionNotFound = errors.New("session not found")
+	ErrPoolClosed      = errors.New("sessions pool is closed")
+)
 
-type ActiveSessionsPool struct {
+// _poolShards is the number of the independently locked parts of the pool.
+const _poolShards = 64
+
+type shard struct {
 	mu       sync.Mutex
 	sessions map[SessionID]*Session
 }
 
+// ActiveSessionsPool keeps the sessions in play. The sessions are spread over
+// shards with their own locks, so that the operations on different sessions
+// rarely wait for each other.
+type ActiveSessionsPool struct {
+	shards [_poolShards]shard
+	closed atomic.Bool
+}
+
 func NewActiveSessionsPool() *ActiveSessionsPool {
-	return &ActiveSessionsPool{
-		sessions: make(map[SessionID]*Session),
+	ap := &ActiveSessionsPool{}
+	for i := range ap.shards {
+		ap.shards[i].sessions = make(map[SessionID]*Session)
 	}
+	return ap
 }
 
+func (ap *ActiveSessionsPool) shard(sessionID SessionID) *shard {
+	return &ap.shards[uint64(sessionID)%_poolShards]
+}
+
+// Get returns an active session. If the time of the session is over, the session
+// is removed from the pool and returned with ErrTimeIsLeft.
 func (ap *ActiveSessionsPool) Get(sessionID SessionID, timeNow time.Time) (*Session, error) {
-	ap.mu.Lock()
-	defer ap.mu.Unlock()
-	s, ok := ap.sessions[sessionID]
+	sh := ap.shard(sessionID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	s, ok := sh.sessions[sessionID]
 	if !ok {
-		return nil, fmt.Errorf("session %v not found", sessionID)
+		return nil, fmt.Errorf("%v: %w", sessionID, ErrSessionNotFound)
 	}
 	if !s.CheckTime(timeNow) {
-		delete(ap.sessions, sessionID)
-		return nil, fmt.Errorf("%v: %w", sessionID, ErrTimeIsLeft)
+		delete(sh.sessions, sessionID)
+		return s, fmt.Errorf("%v: %w", sessionID, ErrTimeIsLeft)
 	}
 
-	return ap.sessions[sessionID], nil
+	return s, nil
 }
 
 func (ap *ActiveSessionsPool) Put(session *Session) error {
-	ap.mu.Lock()
-	defer ap.mu.Unlock()
-	if _, ok := ap.sessions[session.sessionID]; ok {
+	sh := ap.shard(session.sessionID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	// Close marks the pool closed before it empties the shards,
+	// so a session put after the check is returned by Close
+	if ap.closed.Load() {
+		return ErrPoolClosed
+	}
+	if _, ok := sh.sessions[session.sessionID]; ok {
 		return fmt.Errorf("%v: %w", session.sessionID, ErrSessionExists)
 	}
-	ap.sessions[session.sessionID] = session
+	sh.sessions[session.sessionID] = session
 	return nil
 }
 
-func (ap *ActiveSessionsPool) Delete(sessionID SessionID) {
-	ap.mu.Lock()
-	defer ap.mu.Unlock()
-	delete(ap.sessions, sessionID)
+// Delete removes the session from the pool and reports whether it was there.
+func (ap *ActiveSessionsPool) Delete(sessionID SessionID) bool {
+	sh := ap.shard(sessionID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	_, ok := sh.sessions[sessionID]
+	delete(sh.sessions, sessionID)
+	return ok
+}
+
+// Expired removes the sessions whose time is over at timeNow from the pool
+// and returns them. The finish time of a returned session is the moment its time was over.
+func (ap *ActiveSessionsPool) Expired(timeNow time.Time) []*Session {
+	var expired []*Session
+	for i := range ap.shards {
+		sh := &ap.shards[i]
+		sh.mu.Lock()
+		for id, s := range sh.sessions {
+			if !s.CheckTime(timeNow) {
+				delete(sh.sessions, id)
+				expired = append(expired, s)
+			}
+		}
+		sh.mu.Unlock()
+	}
+	return expired
+}
+
+// Close removes all the sessions from the pool and returns them.
+// New sessions cannot be put into a closed pool.
+func (ap *ActiveSessionsPool) Close() []*Session {
+	ap.closed.Store(true)
+
+	sessions := make([]*Session, 0)
+	for i := range ap.shards {
+		sh := &ap.shards[i]
+		sh.mu.Lock()
+		for id, s := range sh.sessions {
+			delete(sh.sessions, id)
+			sessions = append(sessions, s)
+		}
+		sh.mu.Unlock()
+	}
+	return sessions
+}
+
+// All returns the sessions of the pool.
+func (ap *ActiveSessionsPool) All() []*Session {
+	sessions := make([]*Session, 0)
+	for i := range ap.shards {
+		sh := &ap.shards[i]
+		sh.mu.Lock()
+		for _, s := range sh.sessions {
+			sessions = append(sessions, s)
+		}
+		sh.mu.Unlock()
+	}
+	return sessions
+}
+
+// Len returns the number of the sessions in the pool.
+func (ap *ActiveSessionsPool) Len() int {
+	n := 0
+	for i := range ap.shards {
+		sh := &ap.shards[i]
+		sh.mu.Lock()
+		n += len(sh.sessions)
+		sh.mu.Unlock()
+	}
+	return n
 }
@@ -0,0 +1,181 @@
+package game
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/game/generator/mocks"
+	"github.com/pelageech/matharena/internal/game/math"
+)
+
+func TestActiveSessionsPoolExpired(t *testing.T) {
+	clck := &clock{}
+	generator := mocks.NewGenerator(t)
+	generator.EXPECT().Generate().Return(math.Num(10))
+
+	pool := NewActiveSessionsPool()
+	short, err := NewSession(1, time.Second, generator, clck.now())
+	assert.NoError(t, err)
+	long, err := NewSession(2, time.Minute, generator, clck.now())
+	assert.NoError(t, err)
+	assert.NoError(t, pool.Put(short))
+	assert.NoError(t, pool.Put(long))
+
+	clck.add(10 * time.Second)
+	assert.Equal(t, []*Session{short}, pool.Expired(clck.now()))
+	assert.Equal(t, time.Time{}.Add(time.Second), short.FinishTime())
+
+	_, err = pool.Get(short.ID(), clck.now())
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+	_, err = pool.Get(long.ID(), clck.now())
+	assert.NoError(t, err)
+
+	assert.Empty(t, pool.Expired(clck.now()))
+}
+
+func TestActiveSessionsPoolClose(t *testing.T) {
+	clck := &clock{}
+	generator := mocks.NewGenerator(t)
+	generator.EXPECT().Generate().Return(math.Num(10))
+
+	pool := NewActiveSessionsPool()
+	s, err := NewSession(1, time.Second, generator, clck.now())
+	assert.NoError(t, err)
+	assert.NoError(t, pool.Put(s))
+
+	assert.Equal(t, []*Session{s}, pool.Close())
+	_, err = pool.Get(s.ID(), clck.now())
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	late, err := NewSession(2, time.Second, generator, clck.now())
+	assert.NoError(t, err)
+	assert.ErrorIs(t, pool.Put(late), ErrPoolClosed)
+}
+
+// constGenerator generates the same expression, it is cheaper than the mock in benchmarks.
+type constGenerator struct{}
+
+func (constGenerator) Generate() math.ExpressionInt {
+	return math.Num(10)
+}
+
+func (constGenerator) Difficulty() generator.Difficulty {
+	return generator.Easy
+}
+
+func TestSessionConcurrentAnswers(t *testing.T) {
+	const answers = 1000
+
+	start := time.Now()
+	s, err := NewSession(1, time.Hour, constGenerator{}, start)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := range answers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = s.Submit(10, start.Add(time.Duration(i)*time.Microsecond))
+			_ = s.State()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, answers, s.Score())
+}
+
+func TestActiveSessionsPoolConcurrent(t *testing.T) {
+	const sessions = 1000
+
+	start := time.Now()
+	pool := NewActiveSessionsPool()
+
+	var wg sync.WaitGroup
+	for i := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := NewSession(i, time.Hour, constGenerator{}, start, WithCustomID(SessionID(i)))
+			assert.NoError(t, err)
+			assert.NoError(t, pool.Put(s))
+
+			got, err := pool.Get(SessionID(i), start)
+			assert.NoError(t, err)
+			assert.NoError(t, got.Answer(10, start.Add(time.Millisecond)))
+		}()
+	}
+
+	// the janitor and the snapshots walk the pool concurrently
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 10 {
+			pool.Expired(start)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 10 {
+			for _, s := range pool.All() {
+				_ = s.State()
+			}
+		}
+	}()
+	wg.Wait()
+
+	assert.Equal(t, sessions, pool.Len())
+	assert.Len(t, pool.Close(), sessions)
+}
+
+// BenchmarkActiveSessionsPool answers random sessions out of thousands in parallel.
+func BenchmarkActiveSessionsPool(b *testing.B) {
+	for _, sessions := range []int{1000, 10000} {
+		b.Run(strconv.Itoa(sessions), func(b *testing.B) {
+			start := time.Now()
+			pool := NewActiveSessionsPool()
+			for i := range sessions {
+				s, err := NewSession(i, 1000*time.Hour, constGenerator{}, start, WithCustomID(SessionID(i)))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := pool.Put(s); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+				for pb.Next() {
+					s, err := pool.Get(SessionID(r.IntN(sessions)), start)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					_, _ = s.Submit(10, start)
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkSessionSubmit answers the same session in parallel.
+func BenchmarkSessionSubmit(b *testing.B) {
+	start := time.Now()
+	s, err := NewSession(1, 1000*time.Hour, constGenerator{}, start)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = s.Submit(10, start)
+		}
+	})
+}
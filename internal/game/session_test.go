@@ -30,10 +30,11 @@ func TestSession(t *testing.T) {
 	generator.EXPECT().Generate().Return(math.Num(30)).Once()
 	generator.EXPECT().Generate().Return(math.Num(40)).Once()
 
-	s := NewSession(42, time.Second, generator, clck.now(), WithDeltas(Deltas{
+	s, err := NewSession(42, time.Second, generator, clck.now(), WithDeltas(Deltas{
 		OnCorrect:   100 * time.Millisecond,
 		OnIncorrect: 100 * time.Millisecond,
 	}))
+	assert.NoError(t, err)
 
 	t.Run("10,correct", func(t *testing.T) {
 		clck.add(200 * time.Millisecond)
@@ -64,3 +65,127 @@ func TestSession(t *testing.T) {
 		assert.Equal(t, clck.now(), s.finishTime)
 	})
 }
+
+func TestSessionSkipsInvalidExpressions(t *testing.T) {
+	clck := &clock{}
+	generator := mocks.NewGenerator(t)
+	generator.EXPECT().Generate().Return(math.Fact{Fact: math.Num(-1)}).Once()
+	generator.EXPECT().Generate().Return(math.Quotient{Dividend: math.Num(1), Divisor: math.Num(0)}).Once()
+	generator.EXPECT().Generate().Return(math.Num(10)).Once()
+
+	s, err := NewSession(42, time.Second, generator, clck.now())
+	assert.NoError(t, err)
+	assert.Equal(t, math.Num(10), s.CurrentExpression())
+}
+
+func TestSessionGenerateFailed(t *testing.T) {
+	clck := &clock{}
+	generator := mocks.NewGenerator(t)
+	generator.EXPECT().Generate().Return(math.Power{Base: math.Num(2), Exp: math.Num(100)})
+	generator.EXPECT().Difficulty().Return(0)
+
+	_, err := NewSession(42, time.Second, generator, clck.now())
+	assert.ErrorIs(t, err, ErrGenerateFailed)
+}
+
+type observedGenerator struct {
+	*mocks.Generator
+	correct []bool
+	latency []time.Duration
+}
+
+func (g *observedGenerator) Observe(correct bool, latency time.Duration) {
+	g.correct = append(g.correct, correct)
+	g.latency = append(g.latency, latency)
+}
+
+func TestSessionObserver(t *testing.T) {
+	clck := &clock{}
+	generator := &observedGenerator{Generator: mocks.NewGenerator(t)}
+	generator.EXPECT().Generate().Return(math.Num(10))
+
+	s, err := NewSession(42, time.Second, generator, clck.now())
+	assert.NoError(t, err)
+
+	clck.add(200 * time.Millisecond)
+	assert.NoError(t, s.Answer(10, clck.now()))
+	clck.add(300 * time.Millisecond)
+	assert.ErrorIs(t, s.Answer(11, clck.now()), ErrAnswerIsIncorrect)
+
+	assert.Equal(t, []bool{true, false}, generator.correct)
+	assert.Equal(t, []time.Duration{200 * time.Millisecond, 300 * time.Millisecond}, generator.latency)
+}
+
+func TestSessionLastAnswer(t *testing.T) {
+	clck := &clock{}
+	generator := mocks.NewGenerator(t)
+	generator.EXPECT().Generate().Return(math.Num(10))
+
+	s, err := NewSession(42, time.Second, generator, clck.now(), WithDeltas(Deltas{
+		OnCorrect:   100 * time.Millisecond,
+		OnIncorrect: 100 * time.Millisecond,
+	}))
+	assert.NoError(t, err)
+	assert.Nil(t, s.LastAnswer())
+
+	clck.add(200 * time.Millisecond)
+	assert.ErrorIs(t, s.Answer(11, clck.now()), ErrAnswerIsIncorrect)
+	assert.Equal(t, &AnswerRecord{
+		Expression:    math.Num(10),
+		CorrectAnswer: 10,
+		Answer:        11,
+		IsCorrect:     false,
+		Latency:       200 * time.Millisecond,
+		TimeLeft:      700 * time.Millisecond,
+		Time:          clck.now(),
+	}, s.LastAnswer())
+
+	clck.add(time.Second)
+	assert.ErrorIs(t, s.Answer(10, clck.now()), ErrTimeIsLeft)
+	assert.Nil(t, s.LastAnswer())
+}
+
+func TestSessionSkip(t *testing.T) {
+	clck := &clock{}
+	generator := mocks.NewGenerator(t)
+	generator.EXPECT().Generate().Return(math.Num(10)).Once()
+	generator.EXPECT().Generate().Return(math.Num(20)).Once()
+
+	s, err := NewSession(42, time.Second, generator, clck.now(), WithDeltas(Deltas{
+		OnCorrect:   100 * time.Millisecond,
+		OnIncorrect: 300 * time.Millisecond,
+	}))
+	assert.NoError(t, err)
+
+	clck.add(200 * time.Millisecond)
+	assert.NoError(t, s.Skip(clck.now()))
+	assert.Equal(t, 500*time.Millisecond, s.TimeLeft())
+	assert.Equal(t, math.Num(20), s.CurrentExpression())
+	assert.Equal(t, 0, s.Score())
+	assert.Nil(t, s.LastAnswer())
+
+	clck.add(300 * time.Millisecond)
+	assert.ErrorIs(t, s.Skip(clck.now()), ErrTimeIsLeft)
+	assert.Equal(t, clck.now().Add(-100*time.Millisecond), s.FinishTime())
+}
+
+func TestSessionStopped(t *testing.T) {
+	clck := &clock{}
+	generator := mocks.NewGenerator(t)
+	generator.EXPECT().Generate().Return(math.Num(10)).Once()
+
+	s, err := NewSession(42, time.Second, generator, clck.now())
+	assert.NoError(t, err)
+
+	clck.add(200 * time.Millisecond)
+	stopTime := clck.now()
+	s.Stop(stopTime)
+
+	// the moves which come after a concurrent stop are late
+	clck.add(100 * time.Millisecond)
+	assert.ErrorIs(t, s.Answer(10, clck.now()), ErrTimeIsLeft)
+	assert.ErrorIs(t, s.Skip(clck.now()), ErrTimeIsLeft)
+	assert.Nil(t, s.LastAnswer())
+	assert.Equal(t, 0, s.Score())
+	assert.Equal(t, stopTime, s.FinishTime())
+}
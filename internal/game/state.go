@@ -0,0 +1,115 @@
+package game
+
+import (
+	"time"
+
+	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/game/math"
+)
+
+// State is the state of an active session which can be stored and restored later.
+type State struct {
+	ID     SessionID `json:"id"`
+	UserID int       `json:"user_id"`
+
+	Expression           math.AST      `json:"expression"`
+	Answer               int           `json:"answer"`
+	Score                int           `json:"score"`
+	TimeLeft             time.Duration `json:"time_left"`
+	StartTime            time.Time     `json:"start_time"`
+	LastUpdateExpression time.Time     `json:"last_update_expression"`
+	Deltas               Deltas        `json:"deltas"`
+
+	// Seed, options and the number of generated expressions recreate the generator.
+	Seed           uint64               `json:"seed"`
+	Generated      int                  `json:"generated"`
+	Difficulty     generator.Difficulty `json:"difficulty"`
+	Adaptive       bool                 `json:"adaptive"`
+	Family         string               `json:"family,omitempty"`
+	NoRepeatWindow int                  `json:"no_repeat_window"`
+
+	// Adaptation is the state of an adaptive generator, nil for the other generators.
+	Adaptation *generator.AdaptiveState `json:"adaptation,omitempty"`
+
+	// NoRepeat are the recent expressions and answers which must not repeat.
+	NoRepeat *generator.NoRepeatState `json:"no_repeat,omitempty"`
+}
+
+// State returns the state of the session.
+func (s *Session) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := State{
+		ID:                   s.sessionID,
+		UserID:               s.userID,
+		Expression:           math.AST{ExpressionInt: s.currentExpression},
+		Answer:               s.answer,
+		Score:                s.score,
+		TimeLeft:             s.timeLeft,
+		StartTime:            s.startTime,
+		LastUpdateExpression: s.lastUpdateExpression,
+		Deltas:               s.deltas,
+		Seed:                 s.seed,
+		Generated:            s.generated,
+		Difficulty:           s.options.Difficulty,
+		Adaptive:             s.options.Adaptive,
+		Family:               s.options.Family,
+		NoRepeatWindow:       s.options.NoRepeatWindow,
+	}
+	if a, ok := generator.As[*generator.AdaptiveGenerator](s.generator); ok {
+		adaptation := a.State()
+		st.Adaptation = &adaptation
+	}
+	if nr, ok := generator.As[*generator.NoRepeatGenerator](s.generator); ok {
+		noRepeat := nr.State()
+		st.NoRepeat = &noRepeat
+	}
+	return st
+}
+
+// Remaining returns the time left to answer the current expression at timeNow.
+// It is zero if the time is over.
+func (st State) Remaining(timeNow time.Time) time.Duration {
+	return max(st.LastUpdateExpression.Add(st.TimeLeft).Sub(timeNow), 0)
+}
+
+// GeneratorOptions returns the options the generator of the state was created with.
+func (st State) GeneratorOptions() generator.Options {
+	return generator.Options{
+		Difficulty:     st.Difficulty,
+		Adaptive:       st.Adaptive,
+		Family:         st.Family,
+		NoRepeatWindow: st.NoRepeatWindow,
+	}
+}
+
+// RestoreSession creates a session from its state. The generator must be created
+// from the seed and the options of the state and must have already generated
+// st.Generated expressions, so that the session continues the same sequence.
+// An adaptive generator is restored from st.Adaptation and st.NoRepeat instead.
+func RestoreSession(st State, gen generator.Generator) *Session {
+	return &Session{
+		sessionID:            st.ID,
+		userID:               st.UserID,
+		currentExpression:    st.Expression.ExpressionInt,
+		answer:               st.Answer,
+		score:                st.Score,
+		timeLeft:             st.TimeLeft,
+		generator:            gen,
+		seed:                 st.Seed,
+		options:              st.GeneratorOptions(),
+		generated:            st.Generated,
+		startTime:            st.StartTime,
+		lastUpdateExpression: st.LastUpdateExpression,
+		deltas:               st.Deltas,
+	}
+}
+
+// Pause shifts the time the current expression was shown by d, so that
+// the time of the session does not run for d, e.g. while the server is down.
+func (s *Session) Pause(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUpdateExpression = s.lastUpdateExpression.Add(d)
+}
@@ -308,3 +308,56 @@ func (a *Authorization) GetUserInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// swagger:route GET /api/user/{id}/stats GetUserStats
+// Get statistics of the user games.
+//
+// Produces:
+// - application/json
+//
+// Schemes: http
+//
+// Parameters:
+// + name: id
+//   in: path
+//   description: UserId.
+//   required: true
+//   type: integer
+//
+// Responses:
+// 200: getUserStatsOkResponse
+// 400: getUserStatsBadRequestError
+// 404: getUserStatsNotFoundError
+// 500: getUserStatsInternalServerError
+
+// GetUserStats is a handler for the get-user-stats endpoint.
+func (a *Authorization) GetUserStats(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		a.ew.Error(w, "User id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	if userID < 1 {
+		a.ew.Error(w, "User id cannot be less than 1", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := a.data.GetUserStats(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			a.ew.Error(w, models.ErrUserNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		a.ew.Error(w, models.ErrInternalServer.Error(), http.StatusInternalServerError)
+		a.logger.Error("unable to get user stats in GetUserStats", "error", err, "userId", userID)
+		return
+	}
+
+	err = ioutil.ToJSON(models.NewGetUserStatsResponse(stats), w)
+	if err != nil {
+		a.ew.Error(w, models.ErrInternalServer.Error(), http.StatusInternalServerError)
+		a.logger.Error("unable to marshal stats in GetUserStats", "error", err, "stats", stats)
+		return
+	}
+}
@@ -6,14 +6,17 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/mock"
 
 	"github.com/pelageech/matharena/internal/data"
 	"github.com/pelageech/matharena/internal/data/mocks"
+	"github.com/pelageech/matharena/internal/models"
 	"github.com/pelageech/matharena/internal/pkg/ioutil"
 )
 
@@ -63,3 +66,59 @@ func TestAuthorization(t *testing.T) {
 		}
 	})
 }
+
+func TestGetUserStats(t *testing.T) {
+	cred := mocks.NewUserCredentials(t)
+	dl := data.New(cred, 24, time.Hour, []byte{0})
+
+	l := log.NewWithOptions(os.Stderr, log.Options{})
+	authHandlers := NewAuthorization(dl, ioutil.JSONErrorWriter{Logger: l}, l)
+
+	r := chi.NewRouter()
+	r.Get("/user/{id}/stats", authHandlers.GetUserStats)
+
+	t.Run("ok", func(t *testing.T) {
+		cred.EXPECT().GetUserInfo(mock.Anything, 1).Return("aboba", "aboba@g.nsu.ru", nil).Once()
+		cred.EXPECT().GetUserStats(mock.Anything, 1).Return(models.UserStats{
+			GamesPlayed:    2,
+			BestScores:     map[string]int{"easy": 10},
+			AverageScore:   7.5,
+			AnswersGiven:   20,
+			CorrectAnswers: 15,
+			AverageLatency: 1500 * time.Millisecond,
+			LongestStreak:  6,
+		}, nil).Once()
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/1/stats", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d, want %d", w.Code, http.StatusOK)
+		}
+
+		var resp models.GetUserStatsResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		want := models.GetUserStatsResponse{
+			GamesPlayed:      2,
+			BestScores:       map[string]int{"easy": 10},
+			AverageScore:     7.5,
+			Accuracy:         0.75,
+			AverageLatencyMS: 1500,
+			LongestStreak:    6,
+		}
+		if !reflect.DeepEqual(resp, want) {
+			t.Fatalf("got %+v, want %+v", resp, want)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		cred.EXPECT().GetUserInfo(mock.Anything, 2).Return("", "", models.ErrUserNotFound).Once()
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/2/stats", nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("got %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+}
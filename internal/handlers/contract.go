@@ -14,6 +14,8 @@ type Datalayer interface {
 	CreateUser(ctx context.Context, user models.User) error
 	SignInUser(ctx context.Context, username, password string) (string, error)
 	GetUserById(ctx context.Context, id int) (models.UserInfo, error)
+	GetUserStats(ctx context.Context, id int) (models.UserStats, error)
+	VerifyToken(token string) (int, error)
 }
 
 // ErrorWriter is an interface that defines the methods for the error writer.
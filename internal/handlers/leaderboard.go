@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/models"
+	"github.com/pelageech/matharena/internal/pkg/ioutil"
+)
+
+const (
+	_defaultLeaderboardLimit = 20
+	_maxLeaderboardLimit     = 100
+)
+
+type LeaderboardDatalayer interface {
+	Leaderboard(ctx context.Context, window models.LeaderboardWindow, difficulty string, limit, offset, userID int, timeNow time.Time) (models.LeaderboardResponse, error)
+}
+
+type LeaderboardHandler struct {
+	data   LeaderboardDatalayer
+	ew     ErrorWriter
+	logger Logger
+}
+
+func NewLeaderboardHandler(data LeaderboardDatalayer, ew ErrorWriter, logger Logger) *LeaderboardHandler {
+	return &LeaderboardHandler{data: data, ew: ew, logger: logger}
+}
+
+// Leaderboard is a handler for the leaderboard endpoint. The query parameters are
+// window (all, day or week), difficulty (easy, medium or hard; any if not set),
+// limit and offset.
+func (h *LeaderboardHandler) Leaderboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		h.ew.Error(w, "authorization token is required", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	window, err := models.ParseLeaderboardWindow(query.Get("window"))
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	difficulty := ""
+	if s := query.Get("difficulty"); s != "" {
+		d, err := generator.ParseDifficulty(s)
+		if err != nil {
+			h.ew.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		difficulty = strings.ToLower(d.String())
+	}
+
+	limit, err := intQuery(query.Get("limit"), _defaultLeaderboardLimit)
+	if err != nil || limit < 1 || limit > _maxLeaderboardLimit {
+		h.ew.Error(w, "limit must be from 1 to "+strconv.Itoa(_maxLeaderboardLimit), http.StatusBadRequest)
+		return
+	}
+	offset, err := intQuery(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		h.ew.Error(w, "offset must be non-negative", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.data.Leaderboard(r.Context(), window, difficulty, limit, offset, userID, time.Now())
+	if err != nil {
+		h.ew.Error(w, models.ErrInternalServer.Error(), http.StatusInternalServerError)
+		h.logger.Error("unable to get leaderboard in Leaderboard", "error", err)
+		return
+	}
+
+	if err := ioutil.ToJSON(resp, w); err != nil {
+		h.ew.Error(w, models.ErrInternalServer.Error(), http.StatusInternalServerError)
+		h.logger.Error("unable to marshal leaderboard in Leaderboard", "error", err)
+		return
+	}
+}
+
+// intQuery parses an integer query parameter, def is returned if it is not set.
+func intQuery(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/pelageech/matharena/internal/data"
+	"github.com/pelageech/matharena/internal/data/mocks"
+	"github.com/pelageech/matharena/internal/models"
+	"github.com/pelageech/matharena/internal/pkg/ioutil"
+)
+
+func TestLeaderboard(t *testing.T) {
+	db := mocks.NewLeaderboardDB(t)
+
+	l := log.NewWithOptions(os.Stderr, log.Options{})
+	h := NewLeaderboardHandler(data.NewLeaderboardDataLayer(db), ioutil.JSONErrorWriter{Logger: l}, l)
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = req.WithContext(ContextWithUserID(req.Context(), 7))
+		w := httptest.NewRecorder()
+		h.Leaderboard(w, req)
+		return w
+	}
+
+	t.Run("weekly", func(t *testing.T) {
+		entries := []models.LeaderboardEntry{
+			{Rank: 1, UserID: 3, Username: "aboba", Points: 42},
+			{Rank: 2, UserID: 7, Username: "biba", Points: 40},
+		}
+		matches := mock.MatchedBy(func(q models.LeaderboardQuery) bool {
+			return q.Difficulty == "hard" && q.Limit == 2 && q.Offset == 0 &&
+				q.Since.Weekday() == time.Monday && time.Since(q.Since) < 7*24*time.Hour
+		})
+		db.EXPECT().GetLeaderboard(mock.Anything, matches).Return(entries, nil).Once()
+		db.EXPECT().GetLeaderboardEntry(mock.Anything, matches, 7).Return(&entries[1], nil).Once()
+
+		w := serve("/leaderboard?window=week&difficulty=Hard&limit=2")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp models.LeaderboardResponse
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, models.LeaderboardResponse{
+			Window:     models.LeaderboardWeekly,
+			Difficulty: "hard",
+			Entries:    entries,
+			Me:         &entries[1],
+		}, resp)
+	})
+
+	for _, target := range []string{
+		"/leaderboard?window=month",
+		"/leaderboard?difficulty=insane",
+		"/leaderboard?limit=0",
+		"/leaderboard?limit=1000",
+		"/leaderboard?offset=-1",
+	} {
+		t.Run(target, func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, serve(target).Code)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/pelageech/matharena/internal/models"
+)
+
+type userIDKey struct{}
+
+// ContextWithUserID returns a copy of ctx carrying the ID of the authenticated user.
+func ContextWithUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext returns the ID of the user authenticated by Authenticate.
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(int)
+	return userID, ok
+}
+
+// BearerProtocol is the WebSocket subprotocol offered by the browsers which
+// cannot set the Authorization header of the upgrade. The token is offered
+// as the next subprotocol: Sec-WebSocket-Protocol: bearer, <token>.
+const BearerProtocol = "bearer"
+
+// Authenticate is a middleware which checks the Bearer token in the Authorization
+// header and puts the ID of its owner into the request context.
+// Requests without a valid token are rejected with 401.
+func (a *Authorization) Authenticate(next http.Handler) http.Handler {
+	return a.authenticate(next, func(r *http.Request) string {
+		return r.Header.Get("Authorization")
+	})
+}
+
+// AuthenticateWebSocket is Authenticate for the WebSocket upgrades. A browser
+// cannot set the header of an upgrade, so the token may also be offered as
+// a subprotocol after BearerProtocol. The token is not taken from the query,
+// the URLs are written to the logs.
+func (a *Authorization) AuthenticateWebSocket(next http.Handler) http.Handler {
+	return a.authenticate(next, func(r *http.Request) string {
+		if token := r.Header.Get("Authorization"); token != "" {
+			return token
+		}
+		return protocolToken(r)
+	})
+}
+
+// TokenCookie is the name of the cookie checked by AuthenticateEvents.
+const TokenCookie = "token"
+
+// AuthenticateEvents is Authenticate for the Server-Sent Events. EventSource
+// cannot set headers, so the token may also be passed in TokenCookie.
+// As for AuthenticateWebSocket, the token is not taken from the query.
+func (a *Authorization) AuthenticateEvents(next http.Handler) http.Handler {
+	return a.authenticate(next, func(r *http.Request) string {
+		if token := r.Header.Get("Authorization"); token != "" {
+			return token
+		}
+		if c, err := r.Cookie(TokenCookie); err == nil {
+			return c.Value
+		}
+		return ""
+	})
+}
+
+// protocolToken returns the subprotocol following BearerProtocol in the upgrade request.
+func protocolToken(r *http.Request) string {
+	var protocols []string
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+
+	i := slices.Index(protocols, BearerProtocol)
+	if i < 0 || i+1 == len(protocols) {
+		return ""
+	}
+	return protocols[i+1]
+}
+
+func (a *Authorization) authenticate(next http.Handler, tokenOf func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := tokenOf(r)
+		if token == "" {
+			w.Header().Set("Content-Type", "application/json")
+			a.ew.Error(w, "authorization token is required", http.StatusUnauthorized)
+			return
+		}
+
+		userID, err := a.data.VerifyToken(token)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			if errors.Is(err, models.ErrUnauthorized) {
+				a.ew.Error(w, "invalid authorization token", http.StatusUnauthorized)
+				return
+			}
+
+			a.logger.Error("Unable to verify token", "error", err)
+			a.ew.Error(w, models.ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ContextWithUserID(r.Context(), userID)))
+	})
+}
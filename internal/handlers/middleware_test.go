@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/pelageech/matharena/internal/data"
+	"github.com/pelageech/matharena/internal/data/mocks"
+	"github.com/pelageech/matharena/internal/pkg/ioutil"
+)
+
+// signToken returns the token of the user 42 signed with key.
+func signToken(t *testing.T, key []byte, exp time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"username": "aboba",
+		"user_id":  42,
+		"exp":      exp.Unix(),
+	})
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAuthenticate(t *testing.T) {
+	key := []byte{0}
+	dl := data.New(mocks.NewUserCredentials(t), 24, time.Hour, key)
+
+	l := log.NewWithOptions(os.Stderr, log.Options{})
+	authHandlers := NewAuthorization(dl, ioutil.JSONErrorWriter{Logger: l}, l)
+
+	handler := authHandlers.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := UserIDFromContext(r.Context())
+		if !ok || userID != 42 {
+			t.Errorf("got user %d, %v, want 42", userID, ok)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"valid", "Bearer " + signToken(t, key, time.Now().Add(time.Hour)), http.StatusNoContent},
+		{"missing", "", http.StatusUnauthorized},
+		{"malformed", "Bearer aboba", http.StatusUnauthorized},
+		{"expired", "Bearer " + signToken(t, key, time.Now().Add(-time.Hour)), http.StatusUnauthorized},
+		{"foreign key", "Bearer " + signToken(t, []byte{1}, time.Now().Add(time.Hour)), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/session/create", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("got %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWebSocket(t *testing.T) {
+	key := []byte{0}
+	dl := data.New(mocks.NewUserCredentials(t), 24, time.Hour, key)
+
+	l := log.NewWithOptions(os.Stderr, log.Options{})
+	authHandlers := NewAuthorization(dl, ioutil.JSONErrorWriter{Logger: l}, l)
+
+	handler := authHandlers.AuthenticateWebSocket(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := UserIDFromContext(r.Context())
+		if !ok || userID != 42 {
+			t.Errorf("got user %d, %v, want 42", userID, ok)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	token := signToken(t, key, time.Now().Add(time.Hour))
+	tests := []struct {
+		name     string
+		header   string
+		query    string
+		protocol []string
+		want     int
+	}{
+		{"header", "Bearer " + token, "", nil, http.StatusNoContent},
+		{"protocol", "", "", []string{"bearer, " + token}, http.StatusNoContent},
+		{"protocol headers", "", "", []string{"json", "bearer", token}, http.StatusNoContent},
+		{"missing", "", "", []string{"json"}, http.StatusUnauthorized},
+		{"protocol without token", "", "", []string{"json, bearer"}, http.StatusUnauthorized},
+		// the token of the query would be written to the logs
+		{"query", "", token, nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/session/ws?token="+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			for _, p := range tt.protocol {
+				req.Header.Add("Sec-WebSocket-Protocol", p)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("got %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateEvents(t *testing.T) {
+	key := []byte{0}
+	dl := data.New(mocks.NewUserCredentials(t), 24, time.Hour, key)
+
+	l := log.NewWithOptions(os.Stderr, log.Options{})
+	authHandlers := NewAuthorization(dl, ioutil.JSONErrorWriter{Logger: l}, l)
+
+	handler := authHandlers.AuthenticateEvents(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := UserIDFromContext(r.Context())
+		if !ok || userID != 42 {
+			t.Errorf("got user %d, %v, want 42", userID, ok)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	token := signToken(t, key, time.Now().Add(time.Hour))
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		cookie string
+		want   int
+	}{
+		{"header", "Bearer " + token, "", "", http.StatusNoContent},
+		{"cookie", "", "", token, http.StatusNoContent},
+		{"missing", "", "", "", http.StatusUnauthorized},
+		// the token of the query would be written to the logs
+		{"query", "", token, "", http.StatusUnauthorized},
+		{"malformed cookie", "", "", "aboba", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/session/7/events?token="+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: TokenCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("got %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
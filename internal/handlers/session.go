@@ -2,32 +2,117 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"github.com/charmbracelet/log"
+	"github.com/go-chi/chi/v5"
 	"github.com/pelageech/matharena/internal/game"
 	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/game/math"
+	"github.com/pelageech/matharena/internal/models"
 	"github.com/pelageech/matharena/internal/pkg/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 type GameSessionsDatalayer interface {
-	CreateSession(context.Context, time.Duration, int, generator.Difficulty, time.Time) (*game.Session, error)
+	CreateSession(context.Context, time.Duration, int, generator.Options, time.Time) (*game.Session, error)
 	Answer(context.Context, game.SessionID, int, int, time.Time) (*game.Session, error)
 	Stop(context.Context, game.SessionID, int, time.Time) error
+	Skip(context.Context, game.SessionID, int, time.Time) (*game.Session, error)
+	Session(context.Context, game.SessionID, int, time.Time) (*game.Session, error)
+	Result(context.Context, game.SessionID, int) (models.SessionResult, error)
+	Answers(context.Context, game.SessionID, int) ([]game.AnswerRecord, error)
+	History(context.Context, int, models.SessionHistoryQuery) (models.SessionHistoryResponse, error)
 }
 
 type GameSessionsHandler struct {
 	data   GameSessionsDatalayer
 	ew     ErrorWriter
 	logger *log.Logger
+
+	// tickInterval is the interval of the time left pushed to the players.
+	tickInterval time.Duration
+
+	// done is closed on shutdown to end the streams of the sessions.
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 func NewGameSessionsHandler(data GameSessionsDatalayer, ew ErrorWriter, logger *log.Logger) *GameSessionsHandler {
-	return &GameSessionsHandler{data: data, ew: ew, logger: logger}
+	return &GameSessionsHandler{
+		data:         data,
+		ew:           ew,
+		logger:       logger,
+		tickInterval: _defaultTickInterval,
+		done:         make(chan struct{}),
+	}
+}
+
+// Shutdown ends the streams of the sessions opened by Play and Events, so that
+// the server does not wait for them. The sessions themselves are not finished.
+func (h *GameSessionsHandler) Shutdown() {
+	h.closeOnce.Do(func() {
+		close(h.done)
+	})
+}
+
+// userID returns the ID of the user authenticated by Authorization.Authenticate.
+// It writes 401 to the response if the request is not authenticated.
+func (h *GameSessionsHandler) userID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		h.ew.Error(w, "authorization token is required", http.StatusUnauthorized)
+	}
+	return userID, ok
+}
+
+// sessionError writes an error returned by GameSessionsDatalayer.
+func (h *GameSessionsHandler) sessionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrForbidden):
+		h.ew.Error(w, models.ErrForbidden.Error(), http.StatusForbidden)
+	case errors.Is(err, game.ErrSessionNotFound):
+		h.ew.Error(w, game.ErrSessionNotFound.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrUserNotFound):
+		h.ew.Error(w, models.ErrUserNotFound.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrInvalidCursor):
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		h.ew.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
 
 type CreateSessionRequest struct {
-	UserID int `json:"user_id"`
+	// Difficulty is one of "easy", "medium" or "hard". Easy is used if it is not set.
+	Difficulty string `json:"difficulty"`
+
+	// Adaptive makes the difficulty follow the player performance starting from Difficulty.
+	Adaptive bool `json:"adaptive"`
+
+	// Family is the name of a puzzle family loaded from the templates.
+	// Difficulty is ignored if it is set.
+	Family string `json:"family"`
+}
+
+// Options returns the options of the generator of the requested session.
+func (req CreateSessionRequest) Options() (generator.Options, error) {
+	difficulty := generator.Easy
+	if req.Difficulty != "" {
+		var err error
+		difficulty, err = generator.ParseDifficulty(req.Difficulty)
+		if err != nil {
+			return generator.Options{}, err
+		}
+	}
+
+	return generator.Options{
+		Difficulty: difficulty,
+		Adaptive:   req.Adaptive,
+		Family:     req.Family,
+	}, nil
 }
 
 type CreateSessionResponse struct {
@@ -35,29 +120,77 @@ type CreateSessionResponse struct {
 	TimeLeft   time.Duration `json:"time_left"`
 	Expression string        `json:"expression"`
 	Score      int           `json:"score"`
+
+	// Rendered is the expression in the format requested with the format query parameter.
+	Rendered string `json:"rendered,omitempty"`
+}
+
+// rendererFromQuery returns the renderer requested with the format query parameter.
+// It returns nil if the parameter is not set.
+func rendererFromQuery(r *http.Request) (math.Renderer, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		return nil, nil
+	}
+	return math.NewRenderer(format)
+}
+
+func render(renderer math.Renderer, e math.ExpressionInt) string {
+	if renderer == nil {
+		return ""
+	}
+	return string(renderer.Render(e))
 }
 
 func (h *GameSessionsHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
 	r.Header.Set("Content-Type", "application/json")
 
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	renderer, err := rendererFromQuery(r)
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	reqBody := CreateSessionRequest{}
 	if err := ioutil.FromJSON(&reqBody, r.Body); err != nil {
 		h.ew.Error(w, "Unable to unmarshal JSON", http.StatusBadRequest)
 		return
 	}
 
-	s, err := h.data.CreateSession(r.Context(), time.Minute, reqBody.UserID, generator.Easy, time.Now())
+	opts, err := reqBody.Options()
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s, err := h.data.CreateSession(r.Context(), time.Minute, userID, opts, time.Now())
+	if errors.Is(err, generator.ErrUnknownFamily) || errors.Is(err, generator.ErrInvalidOptions) {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, game.ErrPoolClosed) {
+		h.ew.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		h.logger.Errorf("unable to create session: %v", err)
 		h.ew.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// the state is taken at once, the session may be answered concurrently
+	st := s.State()
 	respBody := CreateSessionResponse{
-		SessionID:  s.ID().String(),
-		TimeLeft:   s.TimeLeft(),
-		Expression: string(s.CurrentExpression().Marshal()),
-		Score:      s.Score(),
+		SessionID:  st.ID.String(),
+		TimeLeft:   st.TimeLeft,
+		Expression: string(st.Expression.Marshal()),
+		Score:      st.Score,
+		Rendered:   render(renderer, st.Expression),
 	}
 	if err := ioutil.ToJSON(respBody, w); err != nil {
 		h.logger.Errorf("unable to marshal response: %v", err)
@@ -68,7 +201,6 @@ func (h *GameSessionsHandler) CreateSession(w http.ResponseWriter, r *http.Reque
 
 type AnswerRequest struct {
 	SessionID string `json:"session_id"`
-	UserID    int    `json:"user_id"`
 	Answer    int    `json:"answer"`
 }
 
@@ -77,11 +209,25 @@ type AnswerResponse struct {
 	TimeLeft   time.Duration `json:"time_left"`
 	Expression string        `json:"expression"`
 	Score      int           `json:"score"`
+
+	// Rendered is the expression in the format requested with the format query parameter.
+	Rendered string `json:"rendered,omitempty"`
 }
 
 func (h *GameSessionsHandler) Answer(w http.ResponseWriter, r *http.Request) {
 	r.Header.Set("Content-Type", "application/json")
 
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	renderer, err := rendererFromQuery(r)
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	reqBody := AnswerRequest{}
 	if err := ioutil.FromJSON(&reqBody, r.Body); err != nil {
 		h.ew.Error(w, "Unable to unmarshal JSON", http.StatusBadRequest)
@@ -94,18 +240,21 @@ func (h *GameSessionsHandler) Answer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s, err := h.data.Answer(r.Context(), id, reqBody.Answer, reqBody.UserID, time.Now())
+	s, err := h.data.Answer(r.Context(), id, reqBody.Answer, userID, time.Now())
 	if err != nil {
 		h.logger.Errorf("unable to answer: %v", err)
-		h.ew.Error(w, err.Error(), http.StatusInternalServerError)
+		h.sessionError(w, err)
 		return
 	}
 
+	// the state is taken at once, the session may be answered concurrently
+	st := s.State()
 	respBody := AnswerResponse{
-		SessionID:  s.ID().String(),
-		TimeLeft:   s.TimeLeft(),
-		Expression: string(s.CurrentExpression().Marshal()),
-		Score:      s.Score(),
+		SessionID:  st.ID.String(),
+		TimeLeft:   st.TimeLeft,
+		Expression: string(st.Expression.Marshal()),
+		Score:      st.Score,
+		Rendered:   render(renderer, st.Expression),
 	}
 	if err := ioutil.ToJSON(respBody, w); err != nil {
 		h.logger.Errorf("unable to marshal response: %v", err)
@@ -116,12 +265,16 @@ func (h *GameSessionsHandler) Answer(w http.ResponseWriter, r *http.Request) {
 
 type StopRequest struct {
 	SessionID string `json:"session_id"`
-	UserID    int    `json:"user_id"`
 }
 
 func (h *GameSessionsHandler) Stop(w http.ResponseWriter, r *http.Request) {
 	r.Header.Set("Content-Type", "application/json")
 
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
 	reqBody := StopRequest{}
 	if err := ioutil.FromJSON(&reqBody, r.Body); err != nil {
 		h.ew.Error(w, "Unable to unmarshal JSON", http.StatusBadRequest)
@@ -134,12 +287,265 @@ func (h *GameSessionsHandler) Stop(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.data.Stop(r.Context(), id, reqBody.UserID, time.Now())
+	err = h.data.Stop(r.Context(), id, userID, time.Now())
 	if err != nil {
 		h.logger.Errorf("unable to stop: %v", err)
-		h.ew.Error(w, err.Error(), http.StatusInternalServerError)
+		h.sessionError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Statuses of a session returned by Session.
+const (
+	SessionActive   = "active"
+	SessionFinished = "finished"
+)
+
+type SessionResponse struct {
+	SessionID string `json:"session_id"`
+	Status    string `json:"status"`
+
+	// TimeLeft is the time left to answer the expression now, it is zero for a finished session.
+	TimeLeft   time.Duration `json:"time_left"`
+	Expression string        `json:"expression"`
+	Score      int           `json:"score"`
+	Difficulty string        `json:"difficulty"`
+
+	// Rendered is the expression in the format requested with the format query parameter.
+	Rendered string `json:"rendered,omitempty"`
+
+	// FinishReason is set for a finished session.
+	FinishReason game.FinishReason `json:"finish_reason,omitempty"`
+}
+
+// Session returns the current state of the active session of the user, e.g. to
+// continue the game after the page is reloaded. The session is not changed.
+// A finished session is returned with its stored result and no expression.
+func (h *GameSessionsHandler) Session(w http.ResponseWriter, r *http.Request) {
+	r.Header.Set("Content-Type", "application/json")
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	renderer, err := rendererFromQuery(r)
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := game.ParseSessionID(chi.URLParam(r, "id"))
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timeNow := time.Now()
+	s, err := h.data.Session(r.Context(), id, userID, timeNow)
+	var respBody SessionResponse
+	switch {
+	case err == nil:
+		st := s.State()
+		respBody = SessionResponse{
+			SessionID:  st.ID.String(),
+			Status:     SessionActive,
+			TimeLeft:   st.Remaining(timeNow),
+			Expression: string(st.Expression.Marshal()),
+			Score:      st.Score,
+			Difficulty: strings.ToLower(s.Difficulty().String()),
+			Rendered:   render(renderer, st.Expression),
+		}
+	case errors.Is(err, game.ErrTimeIsLeft):
+		respBody = SessionResponse{
+			SessionID:    id.String(),
+			Status:       SessionFinished,
+			Score:        s.Score(),
+			Difficulty:   strings.ToLower(s.Difficulty().String()),
+			FinishReason: game.FinishTimeout,
+		}
+	case errors.Is(err, game.ErrSessionNotFound):
+		// the session has been finished before, its result is stored
+		res, err := h.data.Result(r.Context(), id, userID)
+		if err != nil {
+			h.logger.Errorf("unable to get session result: %v", err)
+			h.sessionError(w, err)
+			return
+		}
+		respBody = SessionResponse{
+			SessionID:    id.String(),
+			Status:       SessionFinished,
+			Score:        res.Points,
+			Difficulty:   res.Difficulty,
+			FinishReason: game.FinishReason(res.FinishReason),
+		}
+	default:
+		h.logger.Errorf("unable to get session: %v", err)
+		h.sessionError(w, err)
+		return
+	}
+
+	if err := ioutil.ToJSON(respBody, w); err != nil {
+		h.logger.Errorf("unable to marshal response: %v", err)
+		h.ew.Error(w, "unable to marshal JSON", http.StatusInternalServerError)
+		return
+	}
+}
+
+type SessionAnswer struct {
+	Expression string `json:"expression"`
+
+	// AST is the expression as a JSON tree, see math.Node.
+	AST math.AST `json:"ast"`
+
+	CorrectAnswer int           `json:"correct_answer"`
+	Answer        int           `json:"answer"`
+	IsCorrect     bool          `json:"is_correct"`
+	Latency       time.Duration `json:"latency"`
+	TimeLeft      time.Duration `json:"time_left"`
+	Time          time.Time     `json:"time"`
+
+	// Rendered is the expression in the format requested with the format query parameter.
+	Rendered string `json:"rendered,omitempty"`
+}
+
+type AnswersResponse struct {
+	SessionID string          `json:"session_id"`
+	Answers   []SessionAnswer `json:"answers"`
+}
+
+// Answers returns the answers given in the session of the user.
+func (h *GameSessionsHandler) Answers(w http.ResponseWriter, r *http.Request) {
+	r.Header.Set("Content-Type", "application/json")
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	renderer, err := rendererFromQuery(r)
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := game.ParseSessionID(chi.URLParam(r, "id"))
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	answers, err := h.data.Answers(r.Context(), id, userID)
+	if err != nil {
+		h.logger.Errorf("unable to get answers: %v", err)
+		h.sessionError(w, err)
+		return
+	}
+
+	respBody := AnswersResponse{
+		SessionID: id.String(),
+		Answers:   make([]SessionAnswer, 0, len(answers)),
+	}
+	for _, a := range answers {
+		respBody.Answers = append(respBody.Answers, SessionAnswer{
+			Expression:    string(a.Expression.Marshal()),
+			AST:           math.AST{ExpressionInt: a.Expression},
+			CorrectAnswer: a.CorrectAnswer,
+			Answer:        a.Answer,
+			IsCorrect:     a.IsCorrect,
+			Latency:       a.Latency,
+			TimeLeft:      a.TimeLeft,
+			Time:          a.Time,
+			Rendered:      render(renderer, a.Expression),
+		})
+	}
+	if err := ioutil.ToJSON(respBody, w); err != nil {
+		h.logger.Errorf("unable to marshal response: %v", err)
+		h.ew.Error(w, "unable to marshal JSON", http.StatusInternalServerError)
+		return
+	}
+}
+
+const (
+	_defaultHistoryLimit = 20
+	_maxHistoryLimit     = 100
+)
+
+// History lists the finished sessions of the user. The query parameters are
+// difficulty, from and to (RFC 3339 bounds of the start time), sort (start_time
+// or score), limit and cursor (next_cursor of the previous page).
+func (h *GameSessionsHandler) History(w http.ResponseWriter, r *http.Request) {
+	r.Header.Set("Content-Type", "application/json")
+
+	callerID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	q, err := historyQuery(r)
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.data.History(r.Context(), callerID, q)
+	if err != nil {
+		h.logger.Errorf("unable to get history: %v", err)
+		h.sessionError(w, err)
+		return
+	}
+
+	if err := ioutil.ToJSON(resp, w); err != nil {
+		h.logger.Errorf("unable to marshal response: %v", err)
+		h.ew.Error(w, "unable to marshal JSON", http.StatusInternalServerError)
+		return
+	}
+}
+
+func historyQuery(r *http.Request) (models.SessionHistoryQuery, error) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || userID < 1 {
+		return models.SessionHistoryQuery{}, errors.New("user id must be a positive integer")
+	}
+	q := models.SessionHistoryQuery{UserID: userID, Limit: _defaultHistoryLimit}
+
+	query := r.URL.Query()
+	if s := query.Get("difficulty"); s != "" {
+		d, err := generator.ParseDifficulty(s)
+		if err != nil {
+			return models.SessionHistoryQuery{}, err
+		}
+		q.Difficulty = strings.ToLower(d.String())
+	}
+
+	for name, t := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if s := query.Get(name); s != "" {
+			if *t, err = time.Parse(time.RFC3339, s); err != nil {
+				return models.SessionHistoryQuery{}, errors.New(name + " must be an RFC 3339 time")
+			}
+		}
+	}
+
+	if q.Sort, err = models.ParseSessionSort(query.Get("sort")); err != nil {
+		return models.SessionHistoryQuery{}, err
+	}
+
+	if s := query.Get("limit"); s != "" {
+		q.Limit, err = strconv.Atoi(s)
+		if err != nil || q.Limit < 1 || q.Limit > _maxHistoryLimit {
+			return models.SessionHistoryQuery{}, errors.New("limit must be from 1 to " + strconv.Itoa(_maxHistoryLimit))
+		}
+	}
+
+	if s := query.Get("cursor"); s != "" {
+		c, err := models.DecodeSessionCursor(s)
+		if err != nil {
+			return models.SessionHistoryQuery{}, err
+		}
+		q.After = &c
+	}
+
+	return q, nil
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/math"
+)
+
+// EventScore is the type of the event of Events sent when the score changes.
+const EventScore = "score"
+
+// Events streams the changes of the active session of the user as Server-Sent
+// Events, for the clients which cannot use Play. The events are named after
+// the messages of Play: expression when the expression changes, score when
+// the score changes, tick with the time left every tick interval and game_over
+// when the session ends by timeout or stop, then the stream is closed.
+// The data of an event is a PlayEvent.
+func (h *GameSessionsHandler) Events(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	renderer, err := rendererFromQuery(r)
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := game.ParseSessionID(chi.URLParam(r, "id"))
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	timeNow := time.Now()
+	s, err := h.data.Session(ctx, id, userID, timeNow)
+	if err != nil && !errors.Is(err, game.ErrTimeIsLeft) {
+		h.logger.Errorf("unable to get session: %v", err)
+		h.sessionError(w, err)
+		return
+	}
+
+	// the write timeout of the server is meant for requests, the stream lasts longer
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Warnf("unable to reset write deadline: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(e PlayEvent) bool {
+		if err := writeEvent(w, rc, e); err != nil {
+			h.logger.Debugf("events of session %v: %v", id, err)
+			return false
+		}
+		return true
+	}
+
+	if errors.Is(err, game.ErrTimeIsLeft) {
+		write(gameOverEvent(id, s.Score(), game.FinishTimeout))
+		return
+	}
+
+	last := s.State()
+	if !write(expressionEvent(last, nil, renderer, timeNow)) {
+		return
+	}
+
+	ticker := time.NewTicker(h.tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.done:
+			return
+		case t := <-ticker.C:
+			e, st, err := h.poll(ctx, last, userID, renderer, t)
+			if err != nil {
+				h.logger.Errorf("unable to get session: %v", err)
+				continue
+			}
+
+			if e.Score != last.Score {
+				score := PlayEvent{Type: EventScore, SessionID: e.SessionID, TimeLeft: e.TimeLeft, Score: e.Score}
+				if !write(score) {
+					return
+				}
+			}
+			if !write(e) || st == nil {
+				return
+			}
+			last = *st
+		}
+	}
+}
+
+// writeEvent writes the event named after its type and flushes it to the client.
+func writeEvent(w http.ResponseWriter, rc *http.ResponseController, e PlayEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+		return fmt.Errorf("write event: %w", err)
+	}
+	if err := rc.Flush(); err != nil {
+		return fmt.Errorf("flush event: %w", err)
+	}
+	return nil
+}
+
+// poll returns the event of the change of the session since its last state:
+// game_over if the session is finished, expression if the expression has been
+// changed, e.g. by an answer, and tick otherwise. The returned state is nil
+// if the game is over, the reason is the one stored with the result. Polling the store, instead of being notified by the data
+// layer, sees the changes made by the other replicas of the server too.
+func (h *GameSessionsHandler) poll(ctx context.Context, last game.State, userID int, renderer math.Renderer, timeNow time.Time) (PlayEvent, *game.State, error) {
+	s, err := h.data.Session(ctx, last.ID, userID, timeNow)
+	switch {
+	case errors.Is(err, game.ErrTimeIsLeft):
+		return gameOverEvent(last.ID, s.Score(), game.FinishTimeout), nil, nil
+	case errors.Is(err, game.ErrSessionNotFound):
+		// finished by another request, the janitor or on shutdown
+		res, err := h.data.Result(ctx, last.ID, userID)
+		if errors.Is(err, game.ErrSessionNotFound) {
+			// the result is not stored yet, e.g. it is being retried
+			return PlayEvent{Type: PlayTick, SessionID: last.ID.String(), Score: last.Score}, &last, nil
+		}
+		if err != nil {
+			return PlayEvent{}, nil, err
+		}
+		return gameOverEvent(last.ID, res.Points, game.FinishReason(res.FinishReason)), nil, nil
+	case err != nil:
+		return PlayEvent{}, nil, err
+	}
+
+	st := s.State()
+	if st.Generated != last.Generated {
+		return expressionEvent(st, nil, renderer, timeNow), &st, nil
+	}
+	return PlayEvent{Type: PlayTick, SessionID: st.ID.String(), TimeLeft: st.Remaining(timeNow), Score: st.Score}, &st, nil
+}
+
+func expressionEvent(st game.State, correct *bool, renderer math.Renderer, timeNow time.Time) PlayEvent {
+	return PlayEvent{
+		Type:       PlayExpression,
+		SessionID:  st.ID.String(),
+		Expression: string(st.Expression.Marshal()),
+		Rendered:   render(renderer, st.Expression),
+		Correct:    correct,
+		TimeLeft:   st.Remaining(timeNow),
+		Score:      st.Score,
+	}
+}
+
+func gameOverEvent(id game.SessionID, score int, reason game.FinishReason) PlayEvent {
+	return PlayEvent{Type: PlayGameOver, SessionID: id.String(), Score: score, Reason: reason}
+}
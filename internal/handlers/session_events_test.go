@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/pelageech/matharena/internal/data"
+	"github.com/pelageech/matharena/internal/data/mocks"
+	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/models"
+	"github.com/pelageech/matharena/internal/pkg/ioutil"
+)
+
+func TestEvents(t *testing.T) {
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 42, mock.Anything, mock.Anything, mock.Anything).Return(7, nil)
+	db.EXPECT().InsertAnswer(mock.Anything, game.SessionID(7), mock.Anything).Return(nil)
+	db.EXPECT().FinishSession(mock.Anything, mock.Anything, game.FinishStopped).Return(nil).Once()
+
+	l := log.New(io.Discard)
+	dl := data.NewSessionDataLayer(db, nil, l)
+	h := NewGameSessionsHandler(dl, ioutil.JSONErrorWriter{Logger: l}, l)
+	h.tickInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s, err := dl.CreateSession(ctx, time.Minute, 42, generator.Options{}, time.Now())
+	assert.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Get("/{id}/events", func(w http.ResponseWriter, r *http.Request) {
+		userID := 42
+		if r.URL.Query().Has("other") {
+			userID = 1
+		}
+		h.Events(w, r.WithContext(ContextWithUserID(r.Context(), userID)))
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/7/events?other")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/7/events", nil)
+	assert.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	sc := bufio.NewScanner(resp.Body)
+	// next skips the ticks unless a tick is expected
+	next := func(typ string) PlayEvent {
+		t.Helper()
+		var name string
+		for sc.Scan() {
+			line := sc.Text()
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				name = v
+				continue
+			}
+			v, ok := strings.CutPrefix(line, "data: ")
+			if !ok || (name == PlayTick && typ != PlayTick) {
+				continue
+			}
+
+			var e PlayEvent
+			assert.NoError(t, json.Unmarshal([]byte(v), &e))
+			assert.Equal(t, name, e.Type)
+			assert.Equal(t, typ, name)
+			return e
+		}
+		t.Fatalf("stream is closed: %v", sc.Err())
+		return PlayEvent{}
+	}
+
+	e := next(PlayExpression)
+	assert.Equal(t, string(s.CurrentExpression().Marshal()), e.Expression)
+
+	tick := next(PlayTick)
+	assert.Less(t, tick.TimeLeft, time.Minute)
+
+	_, err = dl.Answer(ctx, s.ID(), s.CurrentExpression().Calculate(), 42, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, next(EventScore).Score)
+	e = next(PlayExpression)
+	assert.Equal(t, string(s.CurrentExpression().Marshal()), e.Expression)
+
+	db.EXPECT().GetSessionResult(mock.Anything, game.SessionID(7)).Return(models.SessionResult{
+		UserID:       42,
+		IsFinished:   true,
+		Points:       1,
+		FinishReason: string(game.FinishStopped),
+	}, nil)
+	assert.NoError(t, dl.Stop(ctx, s.ID(), 42, time.Now()))
+	e = next(PlayGameOver)
+	assert.Equal(t, game.FinishStopped, e.Reason)
+	assert.Equal(t, 1, e.Score)
+
+	// the stream is closed after the game is over
+	for sc.Scan() {
+		assert.Empty(t, sc.Text())
+	}
+	assert.NoError(t, sc.Err())
+}
+
+func TestPollFinished(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Now()
+
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 42, t0, mock.Anything, mock.Anything).Return(7, nil)
+	db.EXPECT().FinishSession(mock.Anything, mock.Anything, game.FinishShutdown).Return(nil).Once()
+
+	l := log.New(io.Discard)
+	dl := data.NewSessionDataLayer(db, nil, l)
+	h := NewGameSessionsHandler(dl, ioutil.JSONErrorWriter{Logger: l}, l)
+
+	s, err := dl.CreateSession(ctx, time.Minute, 42, generator.Options{}, t0)
+	assert.NoError(t, err)
+	last := s.State()
+	assert.Equal(t, 1, dl.Shutdown(ctx, t0.Add(time.Second)))
+
+	// the reason is not known until the result is stored
+	db.EXPECT().GetSessionResult(mock.Anything, game.SessionID(7)).Return(models.SessionResult{UserID: 42}, nil).Once()
+	e, st, err := h.poll(ctx, last, 42, nil, t0.Add(2*time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, PlayTick, e.Type)
+	assert.Equal(t, &last, st)
+
+	// the time of the session is left, but it has been finished on shutdown
+	db.EXPECT().GetSessionResult(mock.Anything, game.SessionID(7)).Return(models.SessionResult{
+		UserID:       42,
+		IsFinished:   true,
+		FinishReason: string(game.FinishShutdown),
+	}, nil).Once()
+	e, st, err = h.poll(ctx, last, 42, nil, t0.Add(3*time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, gameOverEvent(7, 0, game.FinishShutdown), e)
+	assert.Nil(t, st)
+}
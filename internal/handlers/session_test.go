@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/pelageech/matharena/internal/data"
+	"github.com/pelageech/matharena/internal/data/mocks"
+	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/game/math"
+	"github.com/pelageech/matharena/internal/models"
+	"github.com/pelageech/matharena/internal/pkg/ioutil"
+)
+
+// serveSession serves the request of the user to the handler with the id URL parameter.
+func serveSession(handler http.HandlerFunc, target, id string, userID int) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	req = req.WithContext(ContextWithUserID(ctx, userID))
+
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestAnswers(t *testing.T) {
+	db := mocks.NewGameSessionsDB(t)
+	l := log.New(io.Discard)
+	h := NewGameSessionsHandler(data.NewSessionDataLayer(db, nil, l), ioutil.JSONErrorWriter{Logger: l}, l)
+
+	expression := math.Sum{math.Num(2), math.Product{math.Num(3), math.Num(4)}}
+	answer := game.AnswerRecord{
+		Expression:    expression,
+		CorrectAnswer: 14,
+		Answer:        14,
+		IsCorrect:     true,
+		Latency:       time.Second,
+		TimeLeft:      time.Minute,
+		Time:          time.Date(2024, time.December, 17, 12, 0, 0, 0, time.UTC),
+	}
+	db.EXPECT().GetUserIDBySession(mock.Anything, game.SessionID(5)).Return(1, nil)
+	db.EXPECT().GetAnswers(mock.Anything, game.SessionID(5)).Return([]game.AnswerRecord{answer}, nil).Once()
+	db.EXPECT().GetUserIDBySession(mock.Anything, game.SessionID(6)).Return(0, game.ErrSessionNotFound)
+
+	w := serveSession(h.Answers, "/5/answers?format=latex", "5", 1)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp AnswersResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, AnswersResponse{
+		SessionID: "5",
+		Answers: []SessionAnswer{{
+			Expression:    "2+3*4",
+			AST:           math.AST{ExpressionInt: expression},
+			CorrectAnswer: 14,
+			Answer:        14,
+			IsCorrect:     true,
+			Latency:       time.Second,
+			TimeLeft:      time.Minute,
+			Time:          answer.Time,
+			Rendered:      `2+3 \cdot 4`,
+		}},
+	}, resp)
+
+	assert.Equal(t, http.StatusForbidden, serveSession(h.Answers, "/5/answers", "5", 2).Code)
+	assert.Equal(t, http.StatusNotFound, serveSession(h.Answers, "/6/answers", "6", 1).Code)
+	assert.Equal(t, http.StatusBadRequest, serveSession(h.Answers, "/x/answers", "x", 1).Code)
+}
+
+func TestSession(t *testing.T) {
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything).Return(5, nil).Once()
+	db.EXPECT().FinishSession(mock.Anything, mock.Anything, game.FinishStopped).Return(nil).Once()
+
+	l := log.New(io.Discard)
+	dl := data.NewSessionDataLayer(db, nil, l)
+	h := NewGameSessionsHandler(dl, ioutil.JSONErrorWriter{Logger: l}, l)
+
+	ctx := context.Background()
+	s, err := dl.CreateSession(ctx, time.Minute, 1, generator.Options{}, time.Now())
+	assert.NoError(t, err)
+
+	get := func(userID int) (int, SessionResponse) {
+		t.Helper()
+		w := serveSession(h.Session, "/5", "5", userID)
+		var resp SessionResponse
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		}
+		return w.Code, resp
+	}
+
+	code, resp := get(1)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, SessionActive, resp.Status)
+	assert.Equal(t, string(s.CurrentExpression().Marshal()), resp.Expression)
+
+	// the stopped session is not in the store, its stored result is returned
+	assert.NoError(t, dl.Stop(ctx, s.ID(), 1, time.Now()))
+	db.EXPECT().GetSessionResult(mock.Anything, game.SessionID(5)).Return(models.SessionResult{
+		UserID:       1,
+		IsFinished:   true,
+		Points:       3,
+		Difficulty:   "easy",
+		FinishReason: string(game.FinishStopped),
+	}, nil)
+
+	code, resp = get(1)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, SessionResponse{
+		SessionID:    "5",
+		Status:       SessionFinished,
+		Score:        3,
+		Difficulty:   "easy",
+		FinishReason: game.FinishStopped,
+	}, resp)
+
+	code, _ = get(2)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// a session which is neither active nor finished is not found
+	db.EXPECT().GetSessionResult(mock.Anything, game.SessionID(6)).Return(models.SessionResult{UserID: 1}, nil)
+	assert.Equal(t, http.StatusNotFound, serveSession(h.Session, "/6", "6", 1).Code)
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/game/math"
+)
+
+const (
+	_defaultTickInterval = time.Second
+
+	// _playWriteTimeout limits the time of sending a message to the player.
+	_playWriteTimeout = 10 * time.Second
+)
+
+// Types of the messages of the WebSocket game protocol sent by the player.
+const (
+	PlayStart  = "start"
+	PlayAnswer = "answer"
+	PlaySkip   = "skip"
+	PlayStop   = "stop"
+)
+
+// Types of the messages of the WebSocket game protocol sent by the server.
+const (
+	PlayTick       = "tick"
+	PlayExpression = "expression"
+	PlayGameOver   = "game_over"
+	PlayError      = "error"
+)
+
+// PlayRequest is a message of the player.
+type PlayRequest struct {
+	Type string `json:"type"`
+
+	// CreateSessionRequest configures the session created by start.
+	CreateSessionRequest
+
+	// SessionID makes start continue the active session instead of creating a new one,
+	// e.g. after the connection is lost.
+	SessionID string `json:"session_id,omitempty"`
+
+	// Answer is the answer to the current expression.
+	Answer int `json:"answer"`
+}
+
+// PlayEvent is a message of the server.
+type PlayEvent struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id,omitempty"`
+
+	// Expression is the current expression, it is sent with expression.
+	Expression string `json:"expression,omitempty"`
+	Rendered   string `json:"rendered,omitempty"`
+
+	// Correct tells whether the answer which changed the expression is correct.
+	Correct *bool `json:"correct,omitempty"`
+
+	TimeLeft time.Duration `json:"time_left"`
+	Score    int           `json:"score"`
+
+	// Reason tells why the game is over, see game.FinishReason.
+	Reason game.FinishReason `json:"reason,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Play upgrades the connection to WebSocket and plays a session with the user.
+// The player sends start, answer, skip and stop messages. The server responds
+// with expression messages, pushes the time left with tick messages every
+// tick interval and sends game_over when the session is finished, even if
+// the player sends nothing. The session is not finished when the connection
+// is closed, start continues it by its ID.
+func (h *GameSessionsHandler) Play(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	renderer, err := rendererFromQuery(r)
+	if err != nil {
+		h.ew.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the timeouts of the server are meant for requests, the game lasts longer
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		h.logger.Warnf("unable to reset read deadline: %v", err)
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Warnf("unable to reset write deadline: %v", err)
+	}
+
+	// the players are authenticated by the token, not by cookies, so any origin is allowed;
+	// a browser offering the token as a subprotocol expects BearerProtocol to be selected
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		InsecureSkipVerify: true,
+		Subprotocols:       []string{BearerProtocol},
+	})
+	if err != nil {
+		h.logger.Errorf("unable to accept websocket: %v", err)
+		return
+	}
+	defer conn.CloseNow()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	requests := make(chan PlayRequest)
+	go func() {
+		defer cancel()
+		for {
+			var req PlayRequest
+			if err := wsjson.Read(ctx, conn, &req); err != nil {
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	p := &player{h: h, conn: conn, userID: userID, renderer: renderer}
+	ticker := time.NewTicker(h.tickInterval)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.done:
+			conn.Close(websocket.StatusGoingAway, "server is shutting down")
+			return
+		case req := <-requests:
+			err = p.handle(ctx, req, time.Now())
+		case t := <-ticker.C:
+			err = p.tick(ctx, t)
+		}
+		if err != nil {
+			h.logger.Debugf("websocket of user %v: %v", userID, err)
+			return
+		}
+	}
+}
+
+// player plays a session over a WebSocket connection. Its methods return
+// an error if the connection is broken.
+type player struct {
+	h        *GameSessionsHandler
+	conn     *websocket.Conn
+	userID   int
+	renderer math.Renderer
+
+	// session is the last state of the session in play, nil if there is none.
+	session *game.State
+}
+
+func (p *player) handle(ctx context.Context, req PlayRequest, timeNow time.Time) error {
+	if req.Type != PlayStart && p.session == nil {
+		return p.error(ctx, errors.New("no session in play, send start"))
+	}
+
+	switch req.Type {
+	case PlayStart:
+		return p.start(ctx, req, timeNow)
+	case PlayAnswer:
+		s, err := p.h.data.Answer(ctx, p.session.ID, req.Answer, p.userID, timeNow)
+		if err != nil {
+			return p.fail(ctx, err, timeNow)
+		}
+		var correct *bool
+		if a := s.LastAnswer(); a != nil {
+			correct = &a.IsCorrect
+		}
+		return p.expression(ctx, s.State(), correct, timeNow)
+	case PlaySkip:
+		s, err := p.h.data.Skip(ctx, p.session.ID, p.userID, timeNow)
+		if err != nil {
+			return p.fail(ctx, err, timeNow)
+		}
+		return p.expression(ctx, s.State(), nil, timeNow)
+	case PlayStop:
+		if err := p.h.data.Stop(ctx, p.session.ID, p.userID, timeNow); err != nil {
+			return p.fail(ctx, err, timeNow)
+		}
+		return p.gameOver(ctx, p.session.Score, game.FinishStopped)
+	}
+	return p.error(ctx, errors.New("unknown message type "+req.Type))
+}
+
+func (p *player) start(ctx context.Context, req PlayRequest, timeNow time.Time) error {
+	if p.session != nil {
+		return p.error(ctx, errors.New("session is in play"))
+	}
+
+	var (
+		s   *game.Session
+		err error
+	)
+	if req.SessionID != "" {
+		var id game.SessionID
+		if id, err = game.ParseSessionID(req.SessionID); err != nil {
+			return p.error(ctx, err)
+		}
+		s, err = p.h.data.Session(ctx, id, p.userID, timeNow)
+		if errors.Is(err, game.ErrTimeIsLeft) {
+			return p.write(ctx, gameOverEvent(id, s.Score(), game.FinishTimeout))
+		}
+	} else {
+		var opts generator.Options
+		if opts, err = req.Options(); err != nil {
+			return p.error(ctx, err)
+		}
+		s, err = p.h.data.CreateSession(ctx, time.Minute, p.userID, opts, timeNow)
+	}
+	if err != nil {
+		p.h.logger.Errorf("unable to start session: %v", err)
+		return p.error(ctx, err)
+	}
+
+	return p.expression(ctx, s.State(), nil, timeNow)
+}
+
+// tick pushes the change of the session in play, see GameSessionsHandler.poll.
+func (p *player) tick(ctx context.Context, timeNow time.Time) error {
+	if p.session == nil {
+		return nil
+	}
+
+	e, st, err := p.h.poll(ctx, *p.session, p.userID, p.renderer, timeNow)
+	if err != nil {
+		p.h.logger.Errorf("unable to get session: %v", err)
+		return nil
+	}
+	p.session = st
+	return p.write(ctx, e)
+}
+
+// fail reports the error of a move. The game is over if the move has finished the session.
+func (p *player) fail(ctx context.Context, err error, timeNow time.Time) error {
+	switch {
+	case errors.Is(err, game.ErrTimeIsLeft):
+		return p.gameOver(ctx, p.session.Score, game.FinishTimeout)
+	case errors.Is(err, game.ErrSessionNotFound):
+		return p.tick(ctx, timeNow)
+	}
+
+	p.h.logger.Errorf("unable to play: %v", err)
+	if wErr := p.error(ctx, err); wErr != nil {
+		return wErr
+	}
+	// the session is stopped if the move failed, e.g. because no expression could be generated
+	return p.tick(ctx, timeNow)
+}
+
+func (p *player) expression(ctx context.Context, st game.State, correct *bool, timeNow time.Time) error {
+	p.session = &st
+	return p.write(ctx, expressionEvent(st, correct, p.renderer, timeNow))
+}
+
+func (p *player) gameOver(ctx context.Context, score int, reason game.FinishReason) error {
+	id := p.session.ID
+	p.session = nil
+	return p.write(ctx, gameOverEvent(id, score, reason))
+}
+
+func (p *player) error(ctx context.Context, err error) error {
+	return p.write(ctx, PlayEvent{Type: PlayError, Error: err.Error()})
+}
+
+func (p *player) write(ctx context.Context, e PlayEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, _playWriteTimeout)
+	defer cancel()
+	return wsjson.Write(ctx, p.conn, e)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/pelageech/matharena/internal/data"
+	"github.com/pelageech/matharena/internal/data/mocks"
+	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/math"
+	"github.com/pelageech/matharena/internal/pkg/ioutil"
+)
+
+func TestPlay(t *testing.T) {
+	db := mocks.NewGameSessionsDB(t)
+	db.EXPECT().CreateSession(mock.Anything, 42, mock.Anything, mock.Anything, mock.Anything).Return(7, nil)
+	db.EXPECT().InsertAnswer(mock.Anything, game.SessionID(7), mock.Anything).Return(nil)
+	db.EXPECT().FinishSession(mock.Anything, mock.Anything, game.FinishStopped).Return(nil).Once()
+
+	l := log.New(io.Discard)
+	h := NewGameSessionsHandler(data.NewSessionDataLayer(db, nil, l), ioutil.JSONErrorWriter{Logger: l}, l)
+	h.tickInterval = 10 * time.Millisecond
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.Play(w, r.WithContext(ContextWithUserID(r.Context(), 42)))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+
+	// next skips the ticks unless a tick is expected
+	next := func(typ string) PlayEvent {
+		t.Helper()
+		for {
+			var e PlayEvent
+			if err := wsjson.Read(ctx, conn, &e); err != nil {
+				t.Fatal(err)
+			}
+			if e.Type == typ || e.Type != PlayTick {
+				assert.Equal(t, typ, e.Type, e.Error)
+				return e
+			}
+		}
+	}
+	send := func(req PlayRequest) {
+		t.Helper()
+		if err := wsjson.Write(ctx, conn, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(PlayRequest{Type: PlayAnswer})
+	next(PlayError)
+
+	send(PlayRequest{Type: PlayStart})
+	e := next(PlayExpression)
+	assert.Equal(t, "7", e.SessionID)
+	assert.Equal(t, 0, e.Score)
+
+	tick := next(PlayTick)
+	assert.Equal(t, "7", tick.SessionID)
+	assert.Less(t, tick.TimeLeft, time.Minute)
+
+	expr, err := math.Parse(e.Expression)
+	assert.NoError(t, err)
+	answer, err := expr.Evaluate()
+	assert.NoError(t, err)
+
+	send(PlayRequest{Type: PlayAnswer, Answer: answer})
+	e = next(PlayExpression)
+	if assert.NotNil(t, e.Correct) {
+		assert.True(t, *e.Correct)
+	}
+	assert.Equal(t, 1, e.Score)
+
+	send(PlayRequest{Type: PlaySkip})
+	e = next(PlayExpression)
+	assert.Nil(t, e.Correct)
+	assert.Equal(t, 1, e.Score)
+
+	send(PlayRequest{Type: PlayStop})
+	e = next(PlayGameOver)
+	assert.Equal(t, game.FinishStopped, e.Reason)
+	assert.Equal(t, 1, e.Score)
+}
+
+func TestPlayAuthentication(t *testing.T) {
+	key := []byte{0}
+	l := log.New(io.Discard)
+	auth := NewAuthorization(data.New(mocks.NewUserCredentials(t), 24, time.Hour, key), ioutil.JSONErrorWriter{Logger: l}, l)
+	h := NewGameSessionsHandler(data.NewSessionDataLayer(mocks.NewGameSessionsDB(t), nil, l), ioutil.JSONErrorWriter{Logger: l}, l)
+
+	srv := httptest.NewServer(auth.AuthenticateWebSocket(http.HandlerFunc(h.Play)))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// a browser offers the token as a subprotocol
+	token := signToken(t, key, time.Now().Add(time.Hour))
+	conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{Subprotocols: []string{BearerProtocol, token}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, BearerProtocol, conn.Subprotocol())
+	conn.Close(websocket.StatusNormalClosure, "")
+
+	for _, u := range []string{url, url + "?token=" + token} {
+		_, resp, err := websocket.Dial(ctx, u, nil)
+		assert.Error(t, err)
+		if assert.NotNil(t, resp) {
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		}
+	}
+}
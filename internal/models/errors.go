@@ -15,4 +15,8 @@ var (
 
 	// ErrUserNotFound is returned when the user is not found in the database.
 	ErrUserNotFound = errors.New("user with specified id not found")
+
+	// ErrForbidden is returned when the authenticated user is not allowed
+	// to access the resource, e.g. a game session of another user.
+	ErrForbidden = errors.New("access denied")
 )
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// LeaderboardWindow is a period of time the leaderboard is computed over.
+type LeaderboardWindow string
+
+const (
+	LeaderboardAllTime LeaderboardWindow = "all"
+	LeaderboardDaily   LeaderboardWindow = "day"
+	LeaderboardWeekly  LeaderboardWindow = "week"
+)
+
+// ParseLeaderboardWindow parses a window, the empty string means all time.
+func ParseLeaderboardWindow(s string) (LeaderboardWindow, error) {
+	switch w := LeaderboardWindow(s); w {
+	case "":
+		return LeaderboardAllTime, nil
+	case LeaderboardAllTime, LeaderboardDaily, LeaderboardWeekly:
+		return w, nil
+	}
+	return "", fmt.Errorf("unknown leaderboard window %q", s)
+}
+
+// Since returns the start of the current day or week (weeks start on Monday) in UTC.
+// It returns the zero time for the all-time window.
+func (w LeaderboardWindow) Since(now time.Time) time.Time {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch w {
+	case LeaderboardDaily:
+		return day
+	case LeaderboardWeekly:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return time.Time{}
+}
+
+// LeaderboardQuery selects a page of the leaderboard.
+type LeaderboardQuery struct {
+	// Since is the earliest end time of the counted sessions, zero means all time.
+	Since time.Time
+
+	// Difficulty filters sessions by difficulty, e.g. "easy". Empty means any.
+	Difficulty string
+
+	Limit  int
+	Offset int
+}
+
+// LeaderboardEntry is the best score of a player.
+type LeaderboardEntry struct {
+	Rank     int    `json:"rank"`
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Points   int    `json:"points"`
+}
+
+// LeaderboardResponse is a struct that defines the response body for the leaderboard endpoint.
+type LeaderboardResponse struct {
+	Window     LeaderboardWindow  `json:"window"`
+	Difficulty string             `json:"difficulty,omitempty"`
+	Entries    []LeaderboardEntry `json:"entries"`
+
+	// Me is the entry of the caller, it is null if the caller has no finished sessions in the window.
+	Me *LeaderboardEntry `json:"me"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaderboardWindowSince(t *testing.T) {
+	// Wednesday
+	now := time.Date(2024, time.November, 27, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		window LeaderboardWindow
+		want   time.Time
+	}{
+		{LeaderboardAllTime, time.Time{}},
+		{LeaderboardDaily, time.Date(2024, time.November, 27, 0, 0, 0, 0, time.UTC)},
+		{LeaderboardWeekly, time.Date(2024, time.November, 25, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := tt.window.Since(now); !got.Equal(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.window, got, tt.want)
+		}
+	}
+
+	// Sunday belongs to the week started on Monday
+	sunday := time.Date(2024, time.December, 1, 23, 0, 0, 0, time.UTC)
+	if got, want := LeaderboardWeekly.Since(sunday), time.Date(2024, time.November, 25, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
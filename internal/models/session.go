@@ -0,0 +1,112 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+// or does not match the sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SessionSort is an order of the session history, the best or the latest sessions go first.
+type SessionSort string
+
+const (
+	SortByStartTime SessionSort = "start_time"
+	SortByScore     SessionSort = "score"
+)
+
+// ParseSessionSort parses a sort order, the empty string means SortByStartTime.
+func ParseSessionSort(s string) (SessionSort, error) {
+	switch o := SessionSort(s); o {
+	case "":
+		return SortByStartTime, nil
+	case SortByStartTime, SortByScore:
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown sort order %q", s)
+}
+
+// SessionCursor points at the last session of a page of the history
+// sorted by Sort. It continues only the history sorted the same way.
+type SessionCursor struct {
+	Sort      SessionSort `json:"sort"`
+	ID        int64       `json:"id"`
+	StartTime time.Time   `json:"start_time"`
+	Points    int         `json:"points"`
+}
+
+// Encode returns an opaque representation of the cursor.
+func (c SessionCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeSessionCursor decodes a cursor returned by SessionCursor.Encode.
+func DecodeSessionCursor(s string) (SessionCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return SessionCursor{}, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	var c SessionCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return SessionCursor{}, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	return c, nil
+}
+
+// SessionHistoryQuery selects a page of the finished sessions of a user.
+type SessionHistoryQuery struct {
+	UserID int
+
+	// Difficulty filters sessions by difficulty, e.g. "easy". Empty means any.
+	Difficulty string
+
+	// From and To bound the start time of the sessions, zero values mean no bound.
+	From time.Time
+	To   time.Time
+
+	Sort  SessionSort
+	Limit int
+
+	// After is the cursor of the previous page, nil for the first page.
+	After *SessionCursor
+}
+
+// SessionHistoryItem is a finished session.
+type SessionHistoryItem struct {
+	ID           int64     `json:"session_id,string"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+	Points       int       `json:"points"`
+	Difficulty   string    `json:"difficulty"`
+	FinishReason string    `json:"finish_reason"`
+}
+
+// SessionResult is the stored state of a session, the result is set once it is finished.
+type SessionResult struct {
+	UserID       int
+	IsFinished   bool
+	Points       int
+	Difficulty   string
+	FinishReason string
+}
+
+// Cursor returns the cursor of the page sorted by sort ending at the item.
+func (i SessionHistoryItem) Cursor(sort SessionSort) SessionCursor {
+	return SessionCursor{Sort: sort, ID: i.ID, StartTime: i.StartTime, Points: i.Points}
+}
+
+// SessionHistoryResponse is a struct that defines the response body for the session history endpoint.
+type SessionHistoryResponse struct {
+	Sessions []SessionHistoryItem `json:"sessions"`
+
+	// NextCursor is passed as the cursor query parameter to get the next page.
+	// It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
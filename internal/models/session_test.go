@@ -0,0 +1,30 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionCursor(t *testing.T) {
+	c := SessionCursor{
+		Sort:      SortByScore,
+		ID:        42,
+		StartTime: time.Date(2024, time.December, 9, 12, 0, 0, 0, time.UTC),
+		Points:    17,
+	}
+
+	got, err := DecodeSessionCursor(c.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != c {
+		t.Fatalf("got %+v, want %+v", got, c)
+	}
+
+	for _, s := range []string{"!!!", "bm90IGpzb24"} {
+		if _, err := DecodeSessionCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%q: got %v, want %v", s, err, ErrInvalidCursor)
+		}
+	}
+}
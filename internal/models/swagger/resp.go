@@ -81,3 +81,27 @@ type GetUserInfoInternalServerError struct {
 	// in: body
 	Body models.GenericError
 }
+
+// swagger:response getUserStatsOkResponse
+type GetUserStatsOkResponse struct {
+	// in: body
+	Body models.GetUserStatsResponse
+}
+
+// swagger:response getUserStatsBadRequestError
+type GetUserStatsBadRequestError struct {
+	// in: body
+	Body models.GenericError
+}
+
+// swagger:response getUserStatsNotFoundError
+type GetUserStatsNotFoundError struct {
+	// in: body
+	Body models.GenericError
+}
+
+// swagger:response getUserStatsInternalServerError
+type GetUserStatsInternalServerError struct {
+	// in: body
+	Body models.GenericError
+}
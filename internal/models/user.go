@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // User is a struct that defines the user model.
 type User struct {
 	ID       int    `json:"id"`
@@ -64,3 +66,76 @@ type GenericError struct {
 	// example: No no no mister fish you won't go into tazik
 	Message string `json:"message"`
 }
+
+// UserStats is a struct that defines the statistics of the user games.
+type UserStats struct {
+	GamesPlayed int
+
+	// BestScores maps a difficulty, e.g. "easy", to the best score.
+	BestScores   map[string]int
+	AverageScore float64
+
+	AnswersGiven   int
+	CorrectAnswers int
+	AverageLatency time.Duration
+	LongestStreak  int
+
+	// LastPlayed is the start time of the last session, nil if there were none.
+	LastPlayed *time.Time
+}
+
+// swagger:model getUserStatsResponse
+// GetUserStatsResponse is a struct that defines the response body for the getUserStats endpoint.
+type GetUserStatsResponse struct {
+	// Number of finished sessions.
+	//
+	// example: 12
+	GamesPlayed int `json:"games_played"`
+
+	// Best score per difficulty.
+	//
+	// example: {"easy": 30, "hard": 12}
+	BestScores map[string]int `json:"best_scores"`
+
+	// Average score of the finished sessions.
+	//
+	// example: 17.5
+	AverageScore float64 `json:"average_score"`
+
+	// Share of correct answers from 0 to 1.
+	//
+	// example: 0.8
+	Accuracy float64 `json:"accuracy"`
+
+	// Average answer latency in milliseconds.
+	//
+	// example: 2300
+	AverageLatencyMS int64 `json:"average_latency_ms"`
+
+	// The longest run of correct answers within a session.
+	//
+	// example: 14
+	LongestStreak int `json:"longest_streak"`
+
+	// Start time of the last session.
+	LastPlayed *time.Time `json:"last_played"`
+}
+
+// NewGetUserStatsResponse converts the statistics to the response body.
+func NewGetUserStatsResponse(s UserStats) GetUserStatsResponse {
+	resp := GetUserStatsResponse{
+		GamesPlayed:      s.GamesPlayed,
+		BestScores:       s.BestScores,
+		AverageScore:     s.AverageScore,
+		AverageLatencyMS: s.AverageLatency.Milliseconds(),
+		LongestStreak:    s.LongestStreak,
+		LastPlayed:       s.LastPlayed,
+	}
+	if s.AnswersGiven > 0 {
+		resp.Accuracy = float64(s.CorrectAnswers) / float64(s.AnswersGiven)
+	}
+	if resp.BestScores == nil {
+		resp.BestScores = make(map[string]int)
+	}
+	return resp
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pelageech/matharena/internal/models"
+)
+
+// _leaderboardQuery ranks players by their best score among the sessions
+// finished after $1 with the difficulty $2, an empty difficulty matches any.
+const _leaderboardQuery = `
+WITH best AS (
+    SELECT player_id, max(points) AS points
+    FROM game_sessions
+    WHERE is_finished AND end_time >= $1 AND ($2 = '' OR difficulty = $2)
+    GROUP BY player_id
+), ranked AS (
+    SELECT player_id, points, rank() OVER (ORDER BY points DESC) AS rank
+    FROM best
+)
+SELECT r.rank, r.player_id, p.username, r.points
+FROM ranked r JOIN players p ON p.id = r.player_id
+`
+
+// GetLeaderboard returns a page of the leaderboard ordered by rank.
+func (d *PSQLDatabase) GetLeaderboard(ctx context.Context, q models.LeaderboardQuery) ([]models.LeaderboardEntry, error) {
+	rows, err := d.Query(ctx, _leaderboardQuery+`ORDER BY r.rank, p.username LIMIT $3 OFFSET $4`,
+		q.Since,
+		q.Difficulty,
+		q.Limit,
+		q.Offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]models.LeaderboardEntry, 0, q.Limit)
+	for rows.Next() {
+		var e models.LeaderboardEntry
+		if err := rows.Scan(&e.Rank, &e.UserID, &e.Username, &e.Points); err != nil {
+			return nil, fmt.Errorf("unable to scan leaderboard entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to get leaderboard: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetLeaderboardEntry returns the entry of the user. It returns nil
+// if the user has no finished sessions matching the query.
+func (d *PSQLDatabase) GetLeaderboardEntry(ctx context.Context, q models.LeaderboardQuery, userID int) (*models.LeaderboardEntry, error) {
+	row := d.QueryRow(ctx, _leaderboardQuery+`WHERE r.player_id = $3`,
+		q.Since,
+		q.Difficulty,
+		userID,
+	)
+
+	var e models.LeaderboardEntry
+	err := row.Scan(&e.Rank, &e.UserID, &e.Username, &e.Points)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get leaderboard entry: %w", err)
+	}
+
+	return &e, nil
+}
@@ -5,9 +5,14 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pelageech/matharena/internal/models"
 )
 
-var ErrUserNotFound = fmt.Errorf("user not found")
+var ErrUserNotFound = fmt.Errorf("user not found: %w", models.ErrUserNotFound)
 
 // GetUserInfo returns username, email and error by given userId.
 func (d *PSQLDatabase) GetUserInfo(ctx context.Context, userId int) (username string, email string, err error) {
@@ -17,7 +22,7 @@ SELECT username, email FROM players WHERE id = $1
 		userId)
 
 	if err := row.Scan(&username, &email); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
 			return "", "", ErrUserNotFound
 		}
 
@@ -119,3 +124,90 @@ SELECT id FROM players
 
 	return ids, nil
 }
+
+// GetUserStats computes the statistics of the user from the finished sessions and their answers.
+func (d *PSQLDatabase) GetUserStats(ctx context.Context, userId int) (models.UserStats, error) {
+	stats := models.UserStats{BestScores: make(map[string]int)}
+
+	row := d.QueryRow(ctx, `
+SELECT count(*) FILTER (WHERE is_finished),
+       coalesce(avg(points) FILTER (WHERE is_finished), 0),
+       max(start_time)
+FROM game_sessions WHERE player_id = $1
+`,
+		userId)
+	if err := row.Scan(&stats.GamesPlayed, &stats.AverageScore, &stats.LastPlayed); err != nil {
+		return models.UserStats{}, fmt.Errorf("unable to get sessions stats in GetUserStats: %w", err)
+	}
+
+	rows, err := d.Query(ctx, `
+SELECT difficulty, max(points) FROM game_sessions
+WHERE player_id = $1 AND is_finished
+GROUP BY difficulty
+`,
+		userId)
+	if err != nil {
+		return models.UserStats{}, fmt.Errorf("unable to get best scores in GetUserStats: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			difficulty string
+			points     int
+		)
+		if err := rows.Scan(&difficulty, &points); err != nil {
+			return models.UserStats{}, fmt.Errorf("unable to get best scores in GetUserStats: %w", err)
+		}
+		stats.BestScores[difficulty] = points
+	}
+	if err := rows.Err(); err != nil {
+		return models.UserStats{}, fmt.Errorf("unable to get best scores in GetUserStats: %w", err)
+	}
+
+	// A streak is a group of consecutive correct answers of a session, the answers
+	// of the group have the same difference of their numbers within the session
+	// and within the correct answers of the session.
+	var latencyMS float64
+	row = d.QueryRow(ctx, `
+WITH answers AS (
+    SELECT a.session_id, a.is_correct, a.latency_ms,
+           row_number() OVER (PARTITION BY a.session_id ORDER BY a.id) -
+           row_number() OVER (PARTITION BY a.session_id, a.is_correct ORDER BY a.id) AS grp
+    FROM session_answers a JOIN game_sessions s ON s.id = a.session_id
+    WHERE s.player_id = $1
+), streaks AS (
+    SELECT count(*) AS streak FROM answers WHERE is_correct GROUP BY session_id, grp
+)
+SELECT count(*),
+       count(*) FILTER (WHERE is_correct),
+       coalesce(avg(latency_ms), 0),
+       coalesce((SELECT max(streak) FROM streaks), 0)
+FROM answers
+`,
+		userId)
+	if err := row.Scan(&stats.AnswersGiven, &stats.CorrectAnswers, &latencyMS, &stats.LongestStreak); err != nil {
+		return models.UserStats{}, fmt.Errorf("unable to get answers stats in GetUserStats: %w", err)
+	}
+	stats.AverageLatency = time.Duration(latencyMS * float64(time.Millisecond))
+
+	return stats, nil
+}
+
+// IsAdmin reports whether the user is an administrator.
+func (d *PSQLDatabase) IsAdmin(ctx context.Context, userId int) (bool, error) {
+	var isAdmin bool
+
+	row := d.QueryRow(ctx, `
+SELECT is_admin FROM players WHERE id = $1
+`,
+		userId)
+	if err := row.Scan(&isAdmin); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrUserNotFound
+		}
+		return false, fmt.Errorf("unable to get is_admin in IsAdmin: %w", err)
+	}
+
+	return isAdmin, nil
+}
@@ -0,0 +1,288 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pelageech/matharena/internal/game"
+)
+
+// _updateAttempts is the number of times an update is retried when the session
+// is changed concurrently by another request or replica.
+const _updateAttempts = 5
+
+var ErrSessionConflict = errors.New("session is changed concurrently")
+
+// querier runs the queries of SessionStore, it is implemented by PSQLDatabase.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// SessionStore keeps the state of the active sessions in the active_sessions
+// table, so that any replica of the server continues any session. Every change
+// increments the version of the row, a change of an outdated state is retried.
+type SessionStore struct {
+	db      querier
+	restore func(game.State) (*game.Session, error)
+	closed  atomic.Bool
+}
+
+// NewSessionStore creates a store which recreates the sessions from their states with restore.
+func NewSessionStore(db *PSQLDatabase, restore func(game.State) (*game.Session, error)) *SessionStore {
+	return &SessionStore{db: db, restore: restore}
+}
+
+// Get returns the session. If the time of the session is over, the session
+// is removed and returned with game.ErrTimeIsLeft.
+func (st *SessionStore) Get(ctx context.Context, id game.SessionID, timeNow time.Time) (*game.Session, error) {
+	s, version, err := st.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.CheckTime(timeNow) {
+		return st.expire(ctx, s, version)
+	}
+	return s, nil
+}
+
+// Put inserts a new session.
+func (st *SessionStore) Put(ctx context.Context, s *game.Session) error {
+	if st.closed.Load() {
+		return game.ErrPoolClosed
+	}
+
+	state, expiresAt, err := marshalState(s)
+	if err != nil {
+		return err
+	}
+	tag, err := st.db.Exec(ctx, `INSERT INTO active_sessions(id, state, version, expires_at) VALUES ($1, $2, 1, $3)
+		ON CONFLICT (id) DO NOTHING`,
+		s.ID(),
+		state,
+		expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting active session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%v: %w", s.ID(), game.ErrSessionExists)
+	}
+	return nil
+}
+
+// Update applies fn to the session and stores the result if nobody has changed
+// the session since it was read, otherwise fn is applied to the new state.
+func (st *SessionStore) Update(ctx context.Context, id game.SessionID, timeNow time.Time, fn func(*game.Session) error) (*game.Session, error) {
+	for range _updateAttempts {
+		s, version, err := st.get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !s.CheckTime(timeNow) {
+			return st.expire(ctx, s, version)
+		}
+
+		fnErr := fn(s)
+
+		state, expiresAt, err := marshalState(s)
+		if err != nil {
+			return nil, err
+		}
+		tag, err := st.db.Exec(ctx, `UPDATE active_sessions SET state = $1, version = version + 1, expires_at = $2
+			WHERE id = $3 AND version = $4`,
+			state,
+			expiresAt,
+			id,
+			version,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error updating active session: %w", err)
+		}
+		if tag.RowsAffected() == 1 {
+			return s, fnErr
+		}
+	}
+	return nil, fmt.Errorf("%v: %w", id, ErrSessionConflict)
+}
+
+// Delete removes the session and reports whether it was there.
+func (st *SessionStore) Delete(ctx context.Context, id game.SessionID) (bool, error) {
+	tag, err := st.db.Exec(ctx, `DELETE FROM active_sessions WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("error deleting active session: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Expired removes the sessions whose time is over at timeNow and returns them.
+// Every session is returned by one replica only. A session which cannot be
+// restored is returned without a generator, so that it is finished anyway;
+// a state which cannot be decoded stays in the table.
+func (st *SessionStore) Expired(ctx context.Context, timeNow time.Time) ([]*game.Session, error) {
+	rows, err := st.db.Query(ctx, `SELECT id, state, version FROM active_sessions WHERE expires_at <= $1`, timeNow)
+	if err != nil {
+		return nil, fmt.Errorf("error getting expired sessions: %w", err)
+	}
+	expired, err := scanVersions(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		sessions = make([]*game.Session, 0, len(expired))
+		errs     []error
+	)
+	for _, row := range expired {
+		var gs game.State
+		if err := json.Unmarshal(row.state, &gs); err != nil {
+			errs = append(errs, fmt.Errorf("decode session state %v: %w", row.id, err))
+			continue
+		}
+
+		// the session answered or removed concurrently is left to the other request
+		tag, err := st.db.Exec(ctx, `DELETE FROM active_sessions WHERE id = $1 AND version = $2`, row.id, row.version)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error deleting expired session %v: %w", row.id, err))
+			continue
+		}
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+
+		s, err := st.restore(gs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("restore session %v: %w", gs.ID, err))
+			// the session needs no generator to be finished
+			s = game.RestoreSession(gs, nil)
+		}
+		s.CheckTime(timeNow)
+		sessions = append(sessions, s)
+	}
+	return sessions, errors.Join(errs...)
+}
+
+// versionedState is a row of active_sessions.
+type versionedState struct {
+	id      game.SessionID
+	state   []byte
+	version int64
+}
+
+func scanVersions(rows pgx.Rows) ([]versionedState, error) {
+	defer rows.Close()
+
+	states := make([]versionedState, 0)
+	for rows.Next() {
+		var v versionedState
+		if err := rows.Scan(&v.id, &v.state, &v.version); err != nil {
+			return nil, fmt.Errorf("error scanning active session: %w", err)
+		}
+		states = append(states, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading active sessions: %w", err)
+	}
+	return states, nil
+}
+
+// Close stops accepting new sessions. It returns nothing, the sessions stay
+// in the table and are continued by the other replicas or after the restart.
+func (st *SessionStore) Close(context.Context) ([]*game.Session, error) {
+	st.closed.Store(true)
+	return nil, nil
+}
+
+// All returns all the active sessions.
+func (st *SessionStore) All(ctx context.Context) ([]*game.Session, error) {
+	rows, err := st.db.Query(ctx, `SELECT state FROM active_sessions`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting active sessions: %w", err)
+	}
+	return st.scan(rows)
+}
+
+func (st *SessionStore) get(ctx context.Context, id game.SessionID) (*game.Session, int64, error) {
+	row := st.db.QueryRow(ctx, `SELECT state, version FROM active_sessions WHERE id = $1`, id)
+
+	var (
+		state   []byte
+		version int64
+	)
+	err := row.Scan(&state, &version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, 0, fmt.Errorf("%v: %w", id, game.ErrSessionNotFound)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting active session: %w", err)
+	}
+
+	s, err := st.unmarshalState(state)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s, version, nil
+}
+
+// expire removes the expired session unless it has been changed or removed concurrently.
+func (st *SessionStore) expire(ctx context.Context, s *game.Session, version int64) (*game.Session, error) {
+	tag, err := st.db.Exec(ctx, `DELETE FROM active_sessions WHERE id = $1 AND version = $2`, s.ID(), version)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting active session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("%v: %w", s.ID(), game.ErrSessionNotFound)
+	}
+	return s, fmt.Errorf("%v: %w", s.ID(), game.ErrTimeIsLeft)
+}
+
+func (st *SessionStore) scan(rows pgx.Rows) ([]*game.Session, error) {
+	defer rows.Close()
+
+	sessions := make([]*game.Session, 0)
+	for rows.Next() {
+		var state []byte
+		if err := rows.Scan(&state); err != nil {
+			return nil, fmt.Errorf("error scanning active session: %w", err)
+		}
+		s, err := st.unmarshalState(state)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading active sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (st *SessionStore) unmarshalState(state []byte) (*game.Session, error) {
+	var gs game.State
+	if err := json.Unmarshal(state, &gs); err != nil {
+		return nil, fmt.Errorf("decode session state: %w", err)
+	}
+	s, err := st.restore(gs)
+	if err != nil {
+		return nil, fmt.Errorf("restore session %v: %w", gs.ID, err)
+	}
+	return s, nil
+}
+
+// marshalState returns the state of the session and the time it expires at
+// unless the player answers.
+func marshalState(s *game.Session) ([]byte, time.Time, error) {
+	gs := s.State()
+	state, err := json.Marshal(gs)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("encode session state %v: %w", gs.ID, err)
+	}
+	return state, gs.LastUpdateExpression.Add(gs.TimeLeft), nil
+}
@@ -0,0 +1,234 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/generator"
+)
+
+// fakeTable is the active_sessions table of one session. The first conflicts
+// reads of the session are followed by a concurrent change of its version.
+type fakeTable struct {
+	state     []byte
+	version   int64
+	exists    bool
+	conflicts int
+	updates   int
+}
+
+func (f *fakeTable) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	switch {
+	case strings.HasPrefix(sql, "INSERT"):
+		if f.exists {
+			return pgconn.NewCommandTag("INSERT 0 0"), nil
+		}
+		f.state, f.version, f.exists = args[1].([]byte), 1, true
+		return pgconn.NewCommandTag("INSERT 0 1"), nil
+	case strings.HasPrefix(sql, "UPDATE"):
+		f.updates++
+		if !f.exists || f.version != args[3].(int64) {
+			return pgconn.NewCommandTag("UPDATE 0"), nil
+		}
+		f.state = args[0].([]byte)
+		f.version++
+		return pgconn.NewCommandTag("UPDATE 1"), nil
+	case strings.HasPrefix(sql, "DELETE"):
+		if !f.exists || len(args) > 1 && f.version != args[1].(int64) {
+			return pgconn.NewCommandTag("DELETE 0"), nil
+		}
+		f.exists = false
+		return pgconn.NewCommandTag("DELETE 1"), nil
+	}
+	return pgconn.CommandTag{}, errors.New("unexpected query: " + sql)
+}
+
+func (f *fakeTable) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
+	return nil, errors.New("unexpected query: " + sql)
+}
+
+func (f *fakeTable) QueryRow(context.Context, string, ...any) pgx.Row {
+	if !f.exists {
+		return fakeRow{err: pgx.ErrNoRows}
+	}
+	state, version := f.state, f.version
+	if f.conflicts > 0 {
+		f.conflicts--
+		f.version++
+	}
+	return fakeRow{state: state, version: version}
+}
+
+type fakeRow struct {
+	state   []byte
+	version int64
+	err     error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*[]byte) = r.state
+	*dest[1].(*int64) = r.version
+	return nil
+}
+
+// fakeActive is the active_sessions table of many sessions read by Expired.
+type fakeActive struct {
+	rows    []versionedState
+	deleted []game.SessionID
+}
+
+func (f *fakeActive) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if !strings.HasPrefix(sql, "DELETE") {
+		return pgconn.CommandTag{}, errors.New("unexpected query: " + sql)
+	}
+	f.deleted = append(f.deleted, args[0].(game.SessionID))
+	return pgconn.NewCommandTag("DELETE 1"), nil
+}
+
+func (f *fakeActive) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return &fakeRows{rows: f.rows, i: -1}, nil
+}
+
+func (f *fakeActive) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	return fakeRow{err: errors.New("unexpected query: " + sql)}
+}
+
+type fakeRows struct {
+	pgx.Rows
+	rows []versionedState
+	i    int
+}
+
+func (r *fakeRows) Next() bool { r.i++; return r.i < len(r.rows) }
+func (r *fakeRows) Err() error { return nil }
+func (r *fakeRows) Close()     {}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	*dest[0].(*game.SessionID) = r.rows[r.i].id
+	*dest[1].(*[]byte) = r.rows[r.i].state
+	*dest[2].(*int64) = r.rows[r.i].version
+	return nil
+}
+
+func restoreEasy(st game.State) (*game.Session, error) {
+	gen := generator.NewEasyGenerator(st.Seed)
+	for range st.Generated {
+		gen.Generate()
+	}
+	return game.RestoreSession(st, gen), nil
+}
+
+func newTestStore(t *testing.T, t0 time.Time) (*SessionStore, *fakeTable) {
+	table := &fakeTable{}
+	st := &SessionStore{db: table, restore: restoreEasy}
+
+	s, err := game.NewSession(1, time.Minute, generator.NewEasyGenerator(1), t0, game.WithCustomID(5), game.WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Put(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	return st, table
+}
+
+func TestSessionStoreUpdate(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 19, 12, 0, 0, 0, time.UTC)
+
+	t.Run("retry", func(t *testing.T) {
+		st, table := newTestStore(t, t0)
+		table.conflicts = 2
+
+		calls := 0
+		s, err := st.Update(ctx, 5, t0.Add(time.Second), func(s *game.Session) error {
+			calls++
+			return s.Answer(s.CurrentExpression().Calculate(), t0.Add(time.Second))
+		})
+		assert.NoError(t, err)
+		// the move is applied to the state read again after every conflict
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, 3, table.updates)
+		assert.Equal(t, int64(4), table.version)
+
+		got, err := st.Get(ctx, 5, t0.Add(time.Second))
+		assert.NoError(t, err)
+		assert.Equal(t, s.State(), got.State())
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		st, table := newTestStore(t, t0)
+		table.conflicts = _updateAttempts
+		state := table.state
+
+		_, err := st.Update(ctx, 5, t0.Add(time.Second), func(s *game.Session) error {
+			return s.Answer(s.CurrentExpression().Calculate(), t0.Add(time.Second))
+		})
+		assert.ErrorIs(t, err, ErrSessionConflict)
+		assert.Equal(t, _updateAttempts, table.updates)
+		assert.Equal(t, state, table.state)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		st, table := newTestStore(t, t0)
+
+		_, err := st.Update(ctx, 5, t0.Add(2*time.Minute), func(*game.Session) error {
+			t.Fatal("expired session is changed")
+			return nil
+		})
+		assert.ErrorIs(t, err, game.ErrTimeIsLeft)
+		assert.False(t, table.exists)
+
+		_, err = st.Update(ctx, 5, t0.Add(2*time.Minute), func(*game.Session) error { return nil })
+		assert.ErrorIs(t, err, game.ErrSessionNotFound)
+	})
+}
+
+func TestSessionStoreExpired(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2024, time.December, 19, 12, 0, 0, 0, time.UTC)
+
+	table := &fakeActive{}
+	for _, id := range []game.SessionID{5, 6, 7} {
+		s, err := game.NewSession(1, time.Minute, generator.NewEasyGenerator(1), t0, game.WithCustomID(id), game.WithSeed(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		state, err := json.Marshal(s.State())
+		if err != nil {
+			t.Fatal(err)
+		}
+		table.rows = append(table.rows, versionedState{id: id, state: state, version: 1})
+	}
+	table.rows = append(table.rows, versionedState{id: 8, state: []byte("{"), version: 1})
+
+	errRestore := errors.New("unknown family")
+	st := &SessionStore{db: table, restore: func(st game.State) (*game.Session, error) {
+		if st.ID == 6 {
+			return nil, errRestore
+		}
+		return restoreEasy(st)
+	}}
+
+	sessions, err := st.Expired(ctx, t0.Add(2*time.Minute))
+	assert.ErrorIs(t, err, errRestore)
+	// the session which cannot be restored is finished along with the others
+	assert.Len(t, sessions, 3)
+	for _, s := range sessions {
+		assert.Equal(t, t0.Add(time.Minute), s.FinishTime())
+	}
+	// the state which cannot be decoded is left in the table
+	assert.Equal(t, []game.SessionID{5, 6, 7}, table.deleted)
+}
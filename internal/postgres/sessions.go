@@ -2,22 +2,37 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5"
 	"github.com/pelageech/matharena/internal/game"
+	"github.com/pelageech/matharena/internal/game/generator"
+	"github.com/pelageech/matharena/internal/game/math"
+	"github.com/pelageech/matharena/internal/models"
+	"strings"
 	"time"
 )
 
-func (p *PSQLDatabase) CreateSession(ctx context.Context, userId int, startTime time.Time) (game.SessionID, error) {
+// CreateSession inserts a new unfinished session. The seed is stored bit by bit
+// in a signed bigint column. The seed and the generator options reproduce
+// the expressions of the session, the difficulty is stored in lower case.
+func (p *PSQLDatabase) CreateSession(ctx context.Context, userId int, startTime time.Time, seed uint64, opts generator.Options) (game.SessionID, error) {
 	_, _, err := p.GetUserInfo(ctx, userId)
 	if err != nil {
 		return -1, fmt.Errorf("%v: %w", userId, ErrUserNotFound)
 	}
 
-	row := p.QueryRow(ctx, `INSERT INTO game_sessions(player_id, start_time, end_time, points, is_finished) VALUES 
-                              ($1, $2, to_timestamp(0), 0, false) RETURNING id`,
+	row := p.QueryRow(ctx, `INSERT INTO game_sessions(player_id, start_time, end_time, points, is_finished, seed,
+                              difficulty, adaptive, family, no_repeat_window) VALUES 
+                              ($1, $2, to_timestamp(0), 0, false, $3, $4, $5, $6, $7) RETURNING id`,
 		userId,
 		startTime,
+		int64(seed),
+		strings.ToLower(opts.Difficulty.String()),
+		opts.Adaptive,
+		opts.Family,
+		opts.NoRepeatWindow,
 	)
 	var id int
 	err = row.Scan(&id)
@@ -27,19 +42,26 @@ func (p *PSQLDatabase) CreateSession(ctx context.Context, userId int, startTime
 
 	return game.SessionID(id), nil
 }
-func (p *PSQLDatabase) FinishSession(ctx context.Context, userID int, id game.SessionID, finishTime time.Time) error {
-	actualUserID, err := p.GetUserIDBySession(ctx, id)
+
+// FinishSession stores the result of the session. The difficulty is not changed,
+// an adaptive session stays grouped under the difficulty it started with.
+func (p *PSQLDatabase) FinishSession(ctx context.Context, s *game.Session, reason game.FinishReason) error {
+	actualUserID, err := p.GetUserIDBySession(ctx, s.ID())
 	if err != nil {
 		return err
 	}
 
-	if actualUserID != userID {
-		return errors.New("forbidden operation")
+	if actualUserID != s.UserID() {
+		return fmt.Errorf("finish session %v of user %v: %w", s.ID(), s.UserID(), models.ErrForbidden)
 	}
 
-	_, err = p.Exec(ctx, `UPDATE game_sessions SET is_finished = true, end_time = $1 WHERE id = $2`,
-		finishTime,
-		id,
+	_, err = p.Exec(ctx, `UPDATE game_sessions
+		SET is_finished = true, end_time = $1, points = $2, finish_reason = $3
+		WHERE id = $4`,
+		s.FinishTime(),
+		s.Score(),
+		string(reason),
+		s.ID(),
 	)
 	if err != nil {
 		return fmt.Errorf("error updating session: %w", err)
@@ -55,9 +77,167 @@ func (p *PSQLDatabase) GetUserIDBySession(ctx context.Context, id game.SessionID
 
 	var gotID int
 	err := row.Scan(&gotID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("%v: %w", id, game.ErrSessionNotFound)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("error getting user id: %w", err)
 	}
 
 	return gotID, nil
 }
+
+// GetSessionResult returns the owner of the session and its result if it is finished.
+func (p *PSQLDatabase) GetSessionResult(ctx context.Context, id game.SessionID) (models.SessionResult, error) {
+	row := p.QueryRow(ctx, `SELECT player_id, is_finished, points, difficulty, coalesce(finish_reason, '')
+		FROM game_sessions WHERE id = $1`,
+		id,
+	)
+
+	var r models.SessionResult
+	err := row.Scan(&r.UserID, &r.IsFinished, &r.Points, &r.Difficulty, &r.FinishReason)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.SessionResult{}, fmt.Errorf("%v: %w", id, game.ErrSessionNotFound)
+	}
+	if err != nil {
+		return models.SessionResult{}, fmt.Errorf("error getting session result: %w", err)
+	}
+
+	return r, nil
+}
+
+// InsertAnswer appends the answer to the history of the session.
+// The expression is stored as the JSON tree of math.AST.
+func (p *PSQLDatabase) InsertAnswer(ctx context.Context, id game.SessionID, a game.AnswerRecord) error {
+	expression, err := json.Marshal(math.AST{ExpressionInt: a.Expression})
+	if err != nil {
+		return fmt.Errorf("error marshaling expression: %w", err)
+	}
+
+	_, err = p.Exec(ctx, `INSERT INTO session_answers(session_id, expression, correct_answer, answer, is_correct,
+                            latency_ms, time_left_ms, answer_time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		id,
+		expression,
+		a.CorrectAnswer,
+		a.Answer,
+		a.IsCorrect,
+		a.Latency.Milliseconds(),
+		a.TimeLeft.Milliseconds(),
+		a.Time,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting answer: %w", err)
+	}
+
+	return nil
+}
+
+// GetAnswers returns the answers of the session in the order they were given.
+func (p *PSQLDatabase) GetAnswers(ctx context.Context, id game.SessionID) ([]game.AnswerRecord, error) {
+	rows, err := p.Query(ctx, `SELECT expression, correct_answer, answer, is_correct, latency_ms, time_left_ms, answer_time
+		FROM session_answers WHERE session_id = $1 ORDER BY id`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting answers: %w", err)
+	}
+	defer rows.Close()
+
+	answers := make([]game.AnswerRecord, 0)
+	for rows.Next() {
+		var (
+			a                 game.AnswerRecord
+			expression        []byte
+			latency, timeLeft int64
+		)
+		err := rows.Scan(&expression, &a.CorrectAnswer, &a.Answer, &a.IsCorrect, &latency, &timeLeft, &a.Time)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning answer: %w", err)
+		}
+
+		var e math.AST
+		if err := json.Unmarshal(expression, &e); err != nil {
+			return nil, fmt.Errorf("error decoding expression %s: %w", expression, err)
+		}
+		a.Expression = e.ExpressionInt
+		a.Latency = time.Duration(latency) * time.Millisecond
+		a.TimeLeft = time.Duration(timeLeft) * time.Millisecond
+		answers = append(answers, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting answers: %w", err)
+	}
+
+	return answers, nil
+}
+
+// GetSessionHistory returns a page of the finished sessions of the user.
+// The sessions are ordered by the sort key and then by id, both descending,
+// so the cursor of the last session of a page starts the next page.
+func (p *PSQLDatabase) GetSessionHistory(ctx context.Context, q models.SessionHistoryQuery) ([]models.SessionHistoryItem, error) {
+	key := "start_time"
+	if q.Sort == models.SortByScore {
+		key = "points"
+	}
+
+	args := []any{q.UserID, q.Difficulty}
+	query := `SELECT id, start_time, end_time, points, difficulty, coalesce(finish_reason, '')
+		FROM game_sessions
+		WHERE player_id = $1 AND is_finished AND ($2 = '' OR difficulty = $2)`
+	if !q.From.IsZero() {
+		args = append(args, q.From)
+		query += fmt.Sprintf(" AND start_time >= $%d", len(args))
+	}
+	if !q.To.IsZero() {
+		args = append(args, q.To)
+		query += fmt.Sprintf(" AND start_time < $%d", len(args))
+	}
+	if c := q.After; c != nil {
+		var after any = c.StartTime
+		if q.Sort == models.SortByScore {
+			after = c.Points
+		}
+		args = append(args, after, c.ID)
+		query += fmt.Sprintf(" AND (%s, id) < ($%d, $%d)", key, len(args)-1, len(args))
+	}
+	args = append(args, q.Limit)
+	query += fmt.Sprintf(" ORDER BY %s DESC, id DESC LIMIT $%d", key, len(args))
+
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting session history: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]models.SessionHistoryItem, 0, q.Limit)
+	for rows.Next() {
+		var i models.SessionHistoryItem
+		if err := rows.Scan(&i.ID, &i.StartTime, &i.EndTime, &i.Points, &i.Difficulty, &i.FinishReason); err != nil {
+			return nil, fmt.Errorf("error scanning session: %w", err)
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting session history: %w", err)
+	}
+
+	return items, nil
+}
+
+// IsSessionFinished reports whether the session is finished.
+func (p *PSQLDatabase) IsSessionFinished(ctx context.Context, id game.SessionID) (bool, error) {
+	row := p.QueryRow(ctx, `SELECT is_finished FROM game_sessions WHERE id = $1`,
+		id,
+	)
+
+	var finished bool
+	err := row.Scan(&finished)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("%v: %w", id, game.ErrSessionNotFound)
+	}
+	if err != nil {
+		return false, fmt.Errorf("error getting session state: %w", err)
+	}
+
+	return finished, nil
+}